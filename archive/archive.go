@@ -0,0 +1,268 @@
+// Package archive converts posts to and from portable archives: a zip of
+// Markdown files with YAML front-matter, or JSON lines.
+package archive
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Archive formats.
+const (
+	FormatMarkdown = "markdown"
+	FormatJSONL    = "jsonl"
+)
+
+// Limits that keep a hostile archive from exhausting memory.
+const (
+	MaxItems    = 5000
+	maxItemSize = 1 << 20
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown archive format")
+	ErrTooManyItems  = fmt.Errorf("archive holds more than %d posts", MaxItems)
+	// ErrItemTooLarge is set on items larger than the per-post limit.
+	ErrItemTooLarge = fmt.Errorf("post larger than %d bytes", maxItemSize)
+)
+
+// Post is the portable form of a post. Slug and UpdatedAt are exported
+// for reference; imports derive slugs from titles again.
+type Post struct {
+	Title     string    `json:"title" yaml:"title"`
+	Slug      string    `json:"slug,omitempty" yaml:"slug,omitempty"`
+	Format    string    `json:"format,omitempty" yaml:"format,omitempty"`
+	Draft     bool      `json:"draft" yaml:"draft"`
+	Tags      []string  `json:"tags,omitempty" yaml:"tags,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty" yaml:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty" yaml:"updated_at,omitempty"`
+	Body      string    `json:"body" yaml:"-"`
+}
+
+// frontMatter also accepts the "date" key other static site and blog
+// tools use for the publication time.
+type frontMatter struct {
+	Post `yaml:",inline"`
+	Date time.Time `yaml:"date,omitempty"`
+}
+
+// Writer streams posts into an archive.
+type Writer struct {
+	format string
+	zip    *zip.Writer
+	json   *json.Encoder
+	names  map[string]int
+}
+
+// NewWriter starts an archive of the given format on w.
+func NewWriter(w io.Writer, format string) (*Writer, error) {
+	switch format {
+	case FormatMarkdown:
+		return &Writer{format: format, zip: zip.NewWriter(w), names: map[string]int{}}, nil
+	case FormatJSONL:
+		return &Writer{format: format, json: json.NewEncoder(w)}, nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// Add appends a post to the archive.
+func (w *Writer) Add(p Post) error {
+	if w.format == FormatJSONL {
+		return w.json.Encode(p)
+	}
+	f, err := w.zip.CreateHeader(&zip.FileHeader{
+		Name:     w.fileName(p),
+		Method:   zip.Deflate,
+		Modified: p.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(Markdown(p))
+	return err
+}
+
+// Close finishes the archive without closing the underlying writer.
+func (w *Writer) Close() error {
+	if w.zip != nil {
+		return w.zip.Close()
+	}
+	return nil
+}
+
+// fileName names a post's file after its slug, numbering repeats.
+func (w *Writer) fileName(p Post) string {
+	base := p.Slug
+	if base == "" {
+		base = "post"
+	}
+	w.names[base]++
+	if n := w.names[base]; n > 1 {
+		base = fmt.Sprintf("%s-%d", base, n)
+	}
+	return "posts/" + base + ".md"
+}
+
+// Markdown renders p as a Markdown document with YAML front-matter.
+func Markdown(p Post) []byte {
+	meta, _ := yaml.Marshal(p)
+	var b bytes.Buffer
+	b.WriteString("---\n")
+	b.Write(meta)
+	b.WriteString("---\n\n")
+	b.WriteString(p.Body)
+	if !strings.HasSuffix(p.Body, "\n") {
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// ParseMarkdown reads a Markdown document with optional front-matter.
+// Documents without a format default to markdown.
+func ParseMarkdown(data []byte) (Post, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	var meta frontMatter
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		header, body, found := strings.Cut(rest, "\n---\n")
+		if !found {
+			return Post{}, errors.New("unterminated front-matter")
+		}
+		if err := yaml.Unmarshal([]byte(header), &meta); err != nil {
+			return Post{}, fmt.Errorf("invalid front-matter: %w", err)
+		}
+		text = body
+	}
+	p := meta.Post
+	p.Body = strings.TrimSpace(text)
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = meta.Date
+	}
+	if p.Format == "" {
+		p.Format = FormatMarkdown
+	}
+	return p, nil
+}
+
+// Item is one post read from an archive, or the reason it could not be read.
+type Item struct {
+	Name string // file name or line number within the archive
+	Post Post
+	Err  error
+}
+
+// Read decodes an archive in either format; zip files are recognised by
+// their signature. Errors in single items are reported on the item, while
+// an unreadable archive fails as a whole.
+func Read(r io.ReaderAt, size int64) ([]Item, error) {
+	head := make([]byte, 512)
+	n, _ := r.ReadAt(head, 0)
+	head = head[:n]
+	if bytes.HasPrefix(head, []byte("PK\x03\x04")) {
+		return readZip(r, size)
+	}
+	if !bytes.HasPrefix(bytes.TrimLeft(head, " \t\r\n"), []byte("{")) {
+		return nil, ErrUnknownFormat
+	}
+	return readJSONL(io.NewSectionReader(r, 0, size))
+}
+
+func readZip(r io.ReaderAt, size int64) ([]Item, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	var items []Item
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !strings.EqualFold(path.Ext(f.Name), ".md") {
+			continue
+		}
+		if len(items) == MaxItems {
+			return nil, ErrTooManyItems
+		}
+		item := Item{Name: f.Name}
+		item.Post, item.Err = readZipFile(f)
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func readZipFile(f *zip.File) (Post, error) {
+	if f.UncompressedSize64 > maxItemSize {
+		return Post{}, ErrItemTooLarge
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return Post{}, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxItemSize+1))
+	if err != nil {
+		return Post{}, err
+	}
+	if len(data) > maxItemSize {
+		return Post{}, ErrItemTooLarge
+	}
+	return ParseMarkdown(data)
+}
+
+func readJSONL(r io.Reader) ([]Item, error) {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	var items []Item
+	for line := 1; ; line++ {
+		text, err := readLine(reader)
+		if err != nil && err != io.EOF && err != ErrItemTooLarge {
+			return nil, err
+		}
+		text = bytes.TrimSpace(text)
+		if len(text) > 0 || err == ErrItemTooLarge {
+			if len(items) == MaxItems {
+				return nil, ErrTooManyItems
+			}
+			item := Item{Name: fmt.Sprintf("line %d", line)}
+			if err == ErrItemTooLarge {
+				item.Err = err
+			} else if err := json.Unmarshal(text, &item.Post); err != nil {
+				item.Err = fmt.Errorf("invalid JSON: %w", err)
+			}
+			items = append(items, item)
+		}
+		if err == io.EOF {
+			return items, nil
+		}
+	}
+}
+
+// readLine reads up to and including the next newline. A line longer
+// than maxItemSize is read to its end and dropped with ErrItemTooLarge,
+// so that the lines after it can still be read.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	tooLarge := false
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if !tooLarge && len(line)+len(bytes.TrimRight(chunk, "\r\n")) > maxItemSize {
+			tooLarge, line = true, nil
+		}
+		if !tooLarge {
+			line = append(line, chunk...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if tooLarge && (err == nil || err == io.EOF) {
+			// An EOF is seen again by the next call
+			return nil, ErrItemTooLarge
+		}
+		return line, err
+	}
+}
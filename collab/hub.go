@@ -0,0 +1,185 @@
+package collab
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait  = 10 * time.Second
+	pongWait   = 60 * time.Second
+	pingPeriod = pongWait * 9 / 10
+	// maxMessage leaves room for an operation inserting a whole body of
+	// multi-byte characters.
+	maxMessage = 4*MaxLength + 4096
+)
+
+// Hub holds the editing sessions of this server instance, one per post.
+// Sessions live in memory, so all editors of a post must reach the same
+// instance.
+type Hub struct {
+	store     Store
+	saveEvery time.Duration
+
+	mu       sync.Mutex
+	sessions map[uint]*session
+	// closing holds the sessions saving their text after the last editor
+	// left, so that a new session for the post waits for the save.
+	closing map[uint]chan struct{}
+}
+
+// NewHub creates a hub that persists sessions through store every
+// saveEvery, and when their last editor leaves.
+func NewHub(store Store, saveEvery time.Duration) *Hub {
+	return &Hub{store: store, saveEvery: saveEvery, sessions: map[uint]*session{}, closing: map[uint]chan struct{}{}}
+}
+
+// Serve runs the connection of one editor of postID until it closes.
+func (h *Hub) Serve(conn *websocket.Conn, postID uint, p Participant) error {
+	id := make([]byte, 8)
+	rand.Read(id)
+	p.ClientID = hex.EncodeToString(id)
+	c := &client{Participant: p, send: make(chan []byte, clientBuffer)}
+
+	h.mu.Lock()
+	for {
+		done, ok := h.closing[postID]
+		if !ok {
+			break
+		}
+		h.mu.Unlock()
+		<-done
+		h.mu.Lock()
+	}
+	s, ok := h.sessions[postID]
+	if !ok {
+		var err error
+		if s, err = newSession(postID, h.store, h.saveEvery); err != nil {
+			h.mu.Unlock()
+			conn.Close()
+			return err
+		}
+		h.sessions[postID] = s
+	}
+	s.join(c)
+	h.mu.Unlock()
+
+	// Leave even when handling a message panics, so the session and its
+	// autosave don't outlive their editors.
+	defer func() {
+		h.mu.Lock()
+		// After Close the session is no longer registered and already closed
+		last := s.leave(c) && h.sessions[postID] == s
+		if last {
+			delete(h.sessions, postID)
+			h.closing[postID] = make(chan struct{})
+		}
+		h.mu.Unlock()
+		if last {
+			h.finish(postID, s)
+		}
+	}()
+
+	go writePump(conn, c.send)
+	readPump(conn, s, c)
+	return nil
+}
+
+// finish saves a session that lost its last editor, without holding
+// h.mu during the save, and lets waiting editors of the post start a new
+// session.
+func (h *Hub) finish(postID uint, s *session) {
+	s.close()
+	h.mu.Lock()
+	done := h.closing[postID]
+	delete(h.closing, postID)
+	h.mu.Unlock()
+	close(done)
+}
+
+// Disconnect closes the connections of accountID to the session of
+// postID, such as when the account stops being a collaborator.
+func (h *Hub) Disconnect(postID, accountID uint) {
+	h.mu.Lock()
+	s, ok := h.sessions[postID]
+	h.mu.Unlock()
+	if ok {
+		s.disconnect(accountID)
+	}
+}
+
+// Close saves every open session and disconnects its editors.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	sessions := h.sessions
+	h.sessions = map[uint]*session{}
+	for postID := range sessions {
+		h.closing[postID] = make(chan struct{})
+	}
+	h.mu.Unlock()
+	for postID, s := range sessions {
+		s.mu.Lock()
+		for c := range s.clients {
+			delete(s.clients, c)
+			close(c.send)
+		}
+		s.mu.Unlock()
+		h.finish(postID, s)
+	}
+}
+
+func readPump(conn *websocket.Conn, s *session, c *client) {
+	conn.SetReadLimit(maxMessage)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			s.mu.Lock()
+			s.sendTo(c, Message{Type: MsgError, Error: "invalid message"})
+			s.mu.Unlock()
+			continue
+		}
+		s.receive(c, msg)
+	}
+}
+
+// writePump forwards queued messages and keeps the connection alive. It
+// closes the connection once send is closed or a write fails, which in
+// turn ends readPump.
+func writePump(conn *websocket.Conn, send <-chan []byte) {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+	for {
+		select {
+		case data, ok := <-send:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
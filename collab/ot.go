@@ -0,0 +1,259 @@
+// Package collab implements live collaborative editing of post bodies
+// with operational transformation. Operations follow the ot.js model:
+// a sequence of retains, inserts and deletes spanning the whole document,
+// with lengths counted in Unicode code points.
+package collab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+var (
+	ErrBaseLength   = errors.New("operation does not match the document length")
+	ErrIncompatible = errors.New("operations have different base lengths")
+)
+
+// Component is one step of an operation. Exactly one field is set.
+type Component struct {
+	Retain int
+	Insert string
+	Delete int
+}
+
+// MarshalJSON encodes retains as positive numbers, deletes as negative
+// numbers and inserts as strings.
+func (c Component) MarshalJSON() ([]byte, error) {
+	switch {
+	case c.Insert != "":
+		return json.Marshal(c.Insert)
+	case c.Delete > 0:
+		return json.Marshal(-c.Delete)
+	}
+	return json.Marshal(c.Retain)
+}
+
+func (c *Component) UnmarshalJSON(data []byte) error {
+	var insert string
+	if err := json.Unmarshal(data, &insert); err == nil {
+		if insert == "" {
+			return errors.New("empty insert")
+		}
+		*c = Component{Insert: insert}
+		return nil
+	}
+	var n int
+	if err := json.Unmarshal(data, &n); err != nil || n == 0 {
+		return fmt.Errorf("invalid operation component %s", data)
+	}
+	// No document is longer than MaxLength, and bounding every length
+	// keeps BaseLen and TargetLen from overflowing.
+	if n > MaxLength || n < -MaxLength {
+		return fmt.Errorf("operation component %s exceeds %d characters", data, MaxLength)
+	}
+	if n > 0 {
+		*c = Component{Retain: n}
+	} else {
+		*c = Component{Delete: -n}
+	}
+	return nil
+}
+
+func (c Component) isRetain() bool { return c.Retain > 0 }
+func (c Component) isInsert() bool { return c.Insert != "" }
+func (c Component) isDelete() bool { return c.Delete > 0 }
+
+// Op is an operation on a whole document.
+type Op []Component
+
+// Normalize rebuilds o with adjacent components merged and inserts
+// placed before deletes, the canonical form Transform relies on.
+func (o Op) Normalize() Op {
+	var out Op
+	for _, c := range o {
+		switch {
+		case c.isRetain():
+			out = out.retain(c.Retain)
+		case c.isInsert():
+			out = out.insert(c.Insert)
+		case c.isDelete():
+			out = out.delete(c.Delete)
+		}
+	}
+	return out
+}
+
+func (o Op) retain(n int) Op {
+	if n <= 0 {
+		return o
+	}
+	if last := len(o) - 1; last >= 0 && o[last].isRetain() {
+		o[last].Retain += n
+		return o
+	}
+	return append(o, Component{Retain: n})
+}
+
+func (o Op) insert(s string) Op {
+	if s == "" {
+		return o
+	}
+	last := len(o) - 1
+	if last >= 0 && o[last].isInsert() {
+		o[last].Insert += s
+		return o
+	}
+	if last >= 0 && o[last].isDelete() {
+		// Insert before the delete; the result is the same
+		if last > 0 && o[last-1].isInsert() {
+			o[last-1].Insert += s
+			return o
+		}
+		o = append(o, o[last])
+		o[last] = Component{Insert: s}
+		return o
+	}
+	return append(o, Component{Insert: s})
+}
+
+func (o Op) delete(n int) Op {
+	if n <= 0 {
+		return o
+	}
+	if last := len(o) - 1; last >= 0 && o[last].isDelete() {
+		o[last].Delete += n
+		return o
+	}
+	return append(o, Component{Delete: n})
+}
+
+// BaseLen is the length of documents o applies to.
+func (o Op) BaseLen() int {
+	n := 0
+	for _, c := range o {
+		n += c.Retain + c.Delete
+	}
+	return n
+}
+
+// TargetLen is the length of documents o produces.
+func (o Op) TargetLen() int {
+	n := 0
+	for _, c := range o {
+		n += c.Retain + utf8.RuneCountInString(c.Insert)
+	}
+	return n
+}
+
+// Apply runs o against doc.
+func Apply(doc string, o Op) (string, error) {
+	runes := []rune(doc)
+	if o.BaseLen() != len(runes) {
+		return "", ErrBaseLength
+	}
+	out := make([]rune, 0, o.TargetLen())
+	pos := 0
+	for _, c := range o {
+		switch {
+		case c.isRetain():
+			out = append(out, runes[pos:pos+c.Retain]...)
+			pos += c.Retain
+		case c.isInsert():
+			out = append(out, []rune(c.Insert)...)
+		case c.isDelete():
+			pos += c.Delete
+		}
+	}
+	return string(out), nil
+}
+
+// Transform takes concurrent operations a and b on the same document and
+// returns a' and b' such that applying a then b' equals applying b then
+// a'. When both insert at the same position, a's text comes first.
+func Transform(a, b Op) (Op, Op, error) {
+	if a.BaseLen() != b.BaseLen() {
+		return nil, nil, ErrIncompatible
+	}
+	var a2, b2 Op
+	ia, ib := 0, 0
+	var ca, cb *Component
+	next := func(o Op, i *int) *Component {
+		if *i >= len(o) {
+			return nil
+		}
+		c := o[*i]
+		*i++
+		return &c
+	}
+	ca, cb = next(a, &ia), next(b, &ib)
+	for ca != nil || cb != nil {
+		if ca != nil && ca.isInsert() {
+			a2 = a2.insert(ca.Insert)
+			b2 = b2.retain(utf8.RuneCountInString(ca.Insert))
+			ca = next(a, &ia)
+			continue
+		}
+		if cb != nil && cb.isInsert() {
+			a2 = a2.retain(utf8.RuneCountInString(cb.Insert))
+			b2 = b2.insert(cb.Insert)
+			cb = next(b, &ib)
+			continue
+		}
+		if ca == nil || cb == nil {
+			return nil, nil, ErrIncompatible
+		}
+		la, lb := ca.Retain+ca.Delete, cb.Retain+cb.Delete
+		n := min(la, lb)
+		switch {
+		case ca.isRetain() && cb.isRetain():
+			a2 = a2.retain(n)
+			b2 = b2.retain(n)
+		case ca.isDelete() && cb.isRetain():
+			a2 = a2.delete(n)
+		case ca.isRetain() && cb.isDelete():
+			b2 = b2.delete(n)
+		}
+		// Deletes on both sides cancel out and produce nothing
+		if la == n {
+			ca = next(a, &ia)
+		} else {
+			shorten(ca, n)
+		}
+		if lb == n {
+			cb = next(b, &ib)
+		} else {
+			shorten(cb, n)
+		}
+	}
+	return a2, b2, nil
+}
+
+func shorten(c *Component, n int) {
+	if c.isRetain() {
+		c.Retain -= n
+	} else {
+		c.Delete -= n
+	}
+}
+
+// TransformIndex moves a cursor position across o.
+func TransformIndex(o Op, index int) int {
+	moved := index
+	for _, c := range o {
+		switch {
+		case c.isRetain():
+			index -= c.Retain
+		case c.isInsert():
+			moved += utf8.RuneCountInString(c.Insert)
+		case c.isDelete():
+			moved -= min(index, c.Delete)
+			index -= c.Delete
+		}
+		if index < 0 {
+			break
+		}
+	}
+	return moved
+}
@@ -0,0 +1,195 @@
+package collab
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func mustApply(t *testing.T, doc string, o Op) string {
+	t.Helper()
+	out, err := Apply(doc, o)
+	if err != nil {
+		t.Fatalf("Apply(%q, %v): %v", doc, o, err)
+	}
+	return out
+}
+
+// checkConvergence asserts apply(apply(doc, a), b') == apply(apply(doc, b), a').
+func checkConvergence(t *testing.T, doc string, a, b Op) {
+	t.Helper()
+	a2, b2, err := Transform(a, b)
+	if err != nil {
+		t.Fatalf("Transform(%v, %v): %v", a, b, err)
+	}
+	left := mustApply(t, mustApply(t, doc, a), b2)
+	right := mustApply(t, mustApply(t, doc, b), a2)
+	if left != right {
+		t.Fatalf("diverged on %q with a=%v b=%v: %q != %q", doc, a, b, left, right)
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		doc  string
+		op   Op
+		want string
+	}{
+		{"hello", Op{{Retain: 5}, {Insert: " world"}}, "hello world"},
+		{"hello", Op{{Delete: 1}, {Insert: "j"}, {Retain: 4}}, "jello"},
+		{"héllo", Op{{Retain: 1}, {Delete: 1}, {Insert: "e"}, {Retain: 3}}, "hello"},
+		{"", Op{{Insert: "new"}}, "new"},
+	}
+	for _, tt := range tests {
+		if got := mustApply(t, tt.doc, tt.op); got != tt.want {
+			t.Errorf("Apply(%q, %v) = %q, want %q", tt.doc, tt.op, got, tt.want)
+		}
+	}
+}
+
+func TestApplyBaseLength(t *testing.T) {
+	if _, err := Apply("hello", Op{{Retain: 4}}); !errors.Is(err, ErrBaseLength) {
+		t.Fatalf("Apply with short base = %v, want ErrBaseLength", err)
+	}
+}
+
+func TestTransformConvergence(t *testing.T) {
+	doc := "hello world"
+	tests := []struct {
+		name string
+		a, b Op
+	}{
+		{"inserts at same position", Op{{Retain: 5}, {Insert: ","}, {Retain: 6}}, Op{{Retain: 5}, {Insert: "!"}, {Retain: 6}}},
+		{"insert inside delete", Op{{Retain: 2}, {Insert: "XY"}, {Retain: 9}}, Op{{Delete: 5}, {Retain: 6}}},
+		{"overlapping deletes", Op{{Retain: 3}, {Delete: 5}, {Retain: 3}}, Op{{Retain: 1}, {Delete: 5}, {Retain: 5}}},
+		{"delete everything", Op{{Delete: 11}}, Op{{Retain: 11}, {Insert: "?"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkConvergence(t, doc, tt.a, tt.b)
+			checkConvergence(t, doc, tt.b, tt.a)
+		})
+	}
+}
+
+func TestTransformTieBreak(t *testing.T) {
+	a := Op{{Insert: "a"}, {Retain: 1}}
+	b := Op{{Insert: "b"}, {Retain: 1}}
+	a2, _, err := Transform(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := mustApply(t, mustApply(t, "x", b), a2); got != "abx" {
+		t.Fatalf("got %q, want a's insert first", got)
+	}
+}
+
+// randomOp builds a random operation on doc.
+func randomOp(r *rand.Rand, doc string) Op {
+	var o Op
+	left := utf8.RuneCountInString(doc)
+	for left > 0 {
+		n := 1 + r.Intn(left)
+		switch r.Intn(3) {
+		case 0:
+			o = o.retain(n)
+		case 1:
+			o = o.delete(n)
+		default:
+			o = o.insert(strings.Repeat("é", 1+r.Intn(3)))
+			continue
+		}
+		left -= n
+	}
+	if r.Intn(2) == 0 {
+		o = o.insert("z")
+	}
+	return o
+}
+
+func TestTransformConvergenceRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		doc := strings.Repeat("ab", r.Intn(8))
+		checkConvergence(t, doc, randomOp(r, doc), randomOp(r, doc))
+	}
+}
+
+func TestTransformIncompatible(t *testing.T) {
+	if _, _, err := Transform(Op{{Retain: 2}}, Op{{Retain: 3}}); !errors.Is(err, ErrIncompatible) {
+		t.Fatalf("Transform with different bases = %v, want ErrIncompatible", err)
+	}
+}
+
+func TestUnmarshalRejectsOversizedComponents(t *testing.T) {
+	for _, payload := range []string{
+		// Sums to 5 once the lengths wrap around
+		`[9223372036854775807,-9223372036854775807,7]`,
+		`[65536]`,
+		`[-65536]`,
+	} {
+		var o Op
+		if err := json.Unmarshal([]byte(payload), &o); err == nil {
+			t.Errorf("Unmarshal(%s) accepted %v", payload, o)
+		}
+	}
+
+	var o Op
+	if err := json.Unmarshal([]byte(`[2,"x",-3]`), &o); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustApply(t, "hello", o); got != "hex" {
+		t.Fatalf("got %q, want %q", got, "hex")
+	}
+}
+
+type memoryStore struct{ text string }
+
+func (m *memoryStore) Load(uint) (string, uint, error) { return m.text, 1, nil }
+func (m *memoryStore) Save(_ uint, text string, v uint) (uint, error) {
+	m.text = text
+	return v + 1, nil
+}
+
+func TestSessionRejectsWrongBase(t *testing.T) {
+	s := &session{store: &memoryStore{}, text: "hello", clients: map[*client]struct{}{}}
+	c := &client{send: make(chan []byte, clientBuffer)}
+	s.clients[c] = struct{}{}
+
+	s.receive(c, Message{Type: MsgOp, Op: Op{{Retain: 9}}})
+	var reply Message
+	if err := json.Unmarshal(<-c.send, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Type != MsgError || s.text != "hello" || s.revision != 0 {
+		t.Fatalf("got %+v with text %q at revision %d, want an error and no change", reply, s.text, s.revision)
+	}
+}
+
+func TestSessionDisconnect(t *testing.T) {
+	s := &session{store: &memoryStore{}, text: "hello", clients: map[*client]struct{}{}}
+	stays := &client{Participant: Participant{AccountID: 1}, send: make(chan []byte, clientBuffer)}
+	removed := &client{Participant: Participant{AccountID: 2}, send: make(chan []byte, clientBuffer)}
+	s.clients[stays] = struct{}{}
+	s.clients[removed] = struct{}{}
+
+	s.disconnect(2)
+	for range removed.send {
+	}
+	var presence Message
+	if err := json.Unmarshal(<-stays.send, &presence); err != nil {
+		t.Fatal(err)
+	}
+	if presence.Type != MsgPresence || len(presence.Participants) != 1 {
+		t.Fatalf("got %+v, want the remaining participant", presence)
+	}
+
+	// Operations the removed client sent before its connection closed are dropped
+	s.receive(removed, Message{Type: MsgOp, Op: Op{{Retain: 5}, {Insert: "!"}}})
+	if s.text != "hello" || s.revision != 0 {
+		t.Fatalf("text %q at revision %d after an op from a removed client", s.text, s.revision)
+	}
+}
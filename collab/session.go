@@ -0,0 +1,308 @@
+package collab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// MaxLength matches the longest body RequestPostBody accepts.
+	MaxLength = 65535
+	// maxHistory is how many past operations are kept for transforming
+	// late operations; clients further behind must resynchronise.
+	maxHistory = 1000
+	// clientBuffer is how many messages a slow client may fall behind
+	// before it is disconnected.
+	clientBuffer = 64
+)
+
+// ErrConflict is returned by Store.Save when the post changed outside
+// the session since it was loaded or last saved.
+var ErrConflict = errors.New("post changed outside the session")
+
+// Store loads and persists the document of a post.
+type Store interface {
+	Load(postID uint) (text string, version uint, err error)
+	// Save writes text if the post is still at version and returns
+	// the new version.
+	Save(postID uint, text string, version uint) (uint, error)
+}
+
+// Participant is a connected editor as shown to the others.
+type Participant struct {
+	ClientID  string `json:"client_id"`
+	AccountID uint   `json:"account_id"`
+	Handle    string `json:"handle"`
+	Cursor    int    `json:"cursor"`
+	Anchor    int    `json:"anchor"`
+}
+
+// Message is the envelope of every message in either direction.
+type Message struct {
+	Type         string        `json:"type"`
+	Revision     int           `json:"revision"`
+	Op           Op            `json:"op,omitempty"`
+	Text         *string       `json:"text,omitempty"`
+	ClientID     string        `json:"client_id,omitempty"`
+	Cursor       int           `json:"cursor,omitempty"`
+	Anchor       int           `json:"anchor,omitempty"`
+	Participants []Participant `json:"participants,omitempty"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// Message types.
+const (
+	MsgInit     = "init"     // server: current text and revision on join
+	MsgOp       = "op"       // both: an operation against a revision
+	MsgAck      = "ack"      // server: the sender's operation was applied
+	MsgPresence = "presence" // both: cursor update, or the participant list
+	MsgReset    = "reset"    // server: the text was replaced, drop pending ops
+	MsgError    = "error"    // server: the last message was rejected
+)
+
+// client is one connection to a session.
+type client struct {
+	Participant
+	send chan []byte
+}
+
+// session is the shared state of one post being edited.
+type session struct {
+	postID uint
+	store  Store
+	stop   chan struct{}
+
+	mu       sync.Mutex
+	text     string
+	version  uint // post version the text was loaded or saved at
+	revision int  // number of operations applied since the session started
+	history  []Op // the last operations, ending at revision
+	dirty    bool
+	clients  map[*client]struct{}
+}
+
+func newSession(postID uint, store Store, saveEvery time.Duration) (*session, error) {
+	text, version, err := store.Load(postID)
+	if err != nil {
+		return nil, err
+	}
+	s := &session{
+		postID:  postID,
+		store:   store,
+		stop:    make(chan struct{}),
+		text:    text,
+		version: version,
+		clients: map[*client]struct{}{},
+	}
+	go s.autosave(saveEvery)
+	return s, nil
+}
+
+func (s *session) autosave(every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			s.sync()
+			s.mu.Unlock()
+		}
+	}
+}
+
+// sync persists unsaved changes, and picks up changes made to the post
+// outside the session; s.mu must be held. When both sides changed, the
+// stored text wins and clients are told to reset, losing the edits made
+// since the last save.
+func (s *session) sync() {
+	if s.dirty {
+		version, err := s.store.Save(s.postID, s.text, s.version)
+		if err == nil {
+			s.version, s.dirty = version, false
+			return
+		}
+		if !errors.Is(err, ErrConflict) {
+			slog.Error("Failed to save post", "post_id", s.postID, "error", err)
+			return
+		}
+	}
+	text, version, err := s.store.Load(s.postID)
+	if err != nil {
+		slog.Error("Failed to reload post", "post_id", s.postID, "error", err)
+		return
+	}
+	if version == s.version {
+		return
+	}
+	s.text, s.version, s.dirty = text, version, false
+	s.revision++
+	s.history = nil
+	s.broadcast(nil, Message{Type: MsgReset, Revision: s.revision, Text: &s.text})
+}
+
+func (s *session) join(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients[c] = struct{}{}
+	s.sendTo(c, Message{Type: MsgInit, Revision: s.revision, Text: &s.text, ClientID: c.ClientID, Participants: s.participants()})
+	s.broadcast(c, Message{Type: MsgPresence, Participants: s.participants()})
+}
+
+// leave removes c and reports whether the session is now empty.
+func (s *session) leave(c *client) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.clients[c]; ok {
+		delete(s.clients, c)
+		close(c.send)
+	}
+	s.broadcast(nil, Message{Type: MsgPresence, Participants: s.participants()})
+	return len(s.clients) == 0
+}
+
+// disconnect closes the connections of accountID. Their read loops then
+// end and leave the session as usual.
+func (s *session) disconnect(accountID uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := false
+	for c := range s.clients {
+		if c.AccountID == accountID {
+			delete(s.clients, c)
+			close(c.send)
+			removed = true
+		}
+	}
+	if removed {
+		s.broadcast(nil, Message{Type: MsgPresence, Participants: s.participants()})
+	}
+}
+
+// close saves outstanding changes and stops the autosave.
+func (s *session) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dirty {
+		s.sync()
+	}
+	close(s.stop)
+}
+
+// receive handles one message from c. Messages still arriving from a
+// disconnected client are dropped.
+func (s *session) receive(c *client, msg Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.clients[c]; !ok {
+		return
+	}
+	switch msg.Type {
+	case MsgOp:
+		op, err := s.apply(msg.Revision, msg.Op.Normalize())
+		if err != nil {
+			s.sendTo(c, Message{Type: MsgError, Error: err.Error()})
+			return
+		}
+		s.sendTo(c, Message{Type: MsgAck, Revision: s.revision})
+		s.broadcast(c, Message{Type: MsgOp, Revision: s.revision, Op: op, ClientID: c.ClientID})
+	case MsgPresence:
+		length := utf8.RuneCountInString(s.text)
+		c.Cursor = clamp(msg.Cursor, length)
+		c.Anchor = clamp(msg.Anchor, length)
+		s.broadcast(c, Message{Type: MsgPresence, Participants: s.participants()})
+	default:
+		s.sendTo(c, Message{Type: MsgError, Error: "unknown message type"})
+	}
+}
+
+// apply transforms op, written against revision, over the operations
+// applied since and applies it; s.mu must be held.
+func (s *session) apply(revision int, op Op) (Op, error) {
+	base := s.revision - len(s.history)
+	if revision < base || revision > s.revision {
+		return nil, fmt.Errorf("revision %d is not available, resynchronise", revision)
+	}
+	for _, concurrent := range s.history[revision-base:] {
+		var err error
+		if op, _, err = Transform(op, concurrent); err != nil {
+			return nil, err
+		}
+	}
+	if op.BaseLen() != utf8.RuneCountInString(s.text) {
+		return nil, ErrBaseLength
+	}
+	if op.TargetLen() > MaxLength {
+		return nil, fmt.Errorf("body would exceed %d characters", MaxLength)
+	}
+	text, err := Apply(s.text, op)
+	if err != nil {
+		return nil, err
+	}
+
+	s.text = text
+	s.revision++
+	s.dirty = true
+	s.history = append(s.history, op)
+	if len(s.history) > maxHistory {
+		s.history = s.history[len(s.history)-maxHistory:]
+	}
+	for c := range s.clients {
+		c.Cursor = TransformIndex(op, c.Cursor)
+		c.Anchor = TransformIndex(op, c.Anchor)
+	}
+	return op, nil
+}
+
+func (s *session) participants() []Participant {
+	list := make([]Participant, 0, len(s.clients))
+	for c := range s.clients {
+		list = append(list, c.Participant)
+	}
+	return list
+}
+
+// broadcast sends msg to every client except skip; s.mu must be held.
+func (s *session) broadcast(skip *client, msg Message) {
+	for c := range s.clients {
+		if c != skip {
+			s.sendTo(c, msg)
+		}
+	}
+}
+
+// sendTo queues msg for c, disconnecting clients that fall too far
+// behind; s.mu must be held.
+func (s *session) sendTo(c *client, msg Message) {
+	if _, ok := s.clients[c]; !ok {
+		return
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		slog.Error("Failed to encode message", "type", msg.Type, "error", err)
+		return
+	}
+	select {
+	case c.send <- data:
+	default:
+		delete(s.clients, c)
+		close(c.send)
+	}
+}
+
+func clamp(n, max int) int {
+	if n < 0 {
+		return 0
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
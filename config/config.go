@@ -0,0 +1,351 @@
+// Package config holds the typed configuration of the server. Values are
+// layered, each source overriding the previous one: built-in defaults, an
+// optional YAML or TOML file, environment variables and command-line flags.
+//
+// Every setting has a dotted path used as the file key and flag name,
+// e.g. database.driver and -database.driver, and most have the
+// environment variable they were read from before, e.g. DB_DRIVER.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Config is the complete server configuration.
+type Config struct {
+	Server    Server    `yaml:"server"`
+	Log       Log       `yaml:"log"`
+	Metrics   Metrics   `yaml:"metrics"`
+	Tracing   Tracing   `yaml:"tracing"`
+	Health    Health    `yaml:"health"`
+	RateLimit RateLimit `yaml:"rate_limit"`
+	Auth      Auth      `yaml:"auth"`
+	Database  Database  `yaml:"database"`
+	Storage   Storage   `yaml:"storage"`
+	Uploads   Uploads   `yaml:"uploads"`
+	Imports   Imports   `yaml:"imports"`
+	Timeline  Timeline  `yaml:"timeline"`
+	Feeds     Feeds     `yaml:"feeds"`
+	Events    Events    `yaml:"events"`
+	Collab    Collab    `yaml:"collab"`
+	Trash     Trash     `yaml:"trash"`
+	Reactions []string  `yaml:"reactions" env:"REACTIONS" validate:"min=1,dive,required,max=32"`
+}
+
+// Server configures the HTTP listener.
+type Server struct {
+	Addr string `yaml:"addr" env:"ADDR" validate:"required"`
+	// SiteURL is the public base URL used in absolute links. When empty
+	// the request's own host is used.
+	SiteURL string `yaml:"site_url" env:"SITE_URL" validate:"omitempty,url"`
+	// TrustedProxies lists the addresses, as IPs or CIDR ranges, whose
+	// X-Forwarded-For and X-Real-IP headers name the client. Requests
+	// from anywhere else are attributed to their remote address.
+	TrustedProxies []string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES" validate:"dive,cidr|ip"`
+
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" validate:"gte=0"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT" validate:"gte=0"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" validate:"gte=0"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" validate:"gte=0"`
+	// ShutdownTimeout bounds how long in-flight requests may take to
+	// finish once the server is asked to stop.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" validate:"gt=0"`
+
+	TLS TLS `yaml:"tls"`
+}
+
+// TLS enables HTTPS when both files are set. Renewed certificates are
+// picked up without a restart.
+type TLS struct {
+	CertFile string `yaml:"cert_file" env:"TLS_CERT_FILE"`
+	KeyFile  string `yaml:"key_file" env:"TLS_KEY_FILE"`
+}
+
+// Enabled reports whether the server should serve HTTPS.
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+// Log configures the structured log output.
+type Log struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" validate:"oneof=debug info warn error"`
+	Format string `yaml:"format" env:"LOG_FORMAT" validate:"oneof=text json"`
+}
+
+// Metrics configures the Prometheus endpoint.
+type Metrics struct {
+	Enabled bool   `yaml:"enabled" env:"METRICS_ENABLED"`
+	Path    string `yaml:"path" env:"METRICS_PATH" validate:"required,startswith=/"`
+}
+
+// Tracing configures OpenTelemetry traces. The otlp exporter sends them
+// over HTTP to Endpoint; the stdout exporter writes them as JSON to File,
+// or to standard output when File is empty.
+type Tracing struct {
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER" validate:"oneof=none otlp stdout"`
+	Endpoint    string  `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" validate:"omitempty,url"`
+	File        string  `yaml:"file" env:"TRACING_FILE"`
+	ServiceName string  `yaml:"service_name" env:"OTEL_SERVICE_NAME" validate:"required"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" validate:"gte=0,lte=1"`
+}
+
+// Health configures the readiness checks.
+type Health struct {
+	// Timeout bounds each dependency check, so a hanging dependency
+	// fails the check instead of the probe.
+	Timeout time.Duration `yaml:"timeout" env:"HEALTH_CHECK_TIMEOUT" validate:"gt=0"`
+}
+
+// RateLimit limits how often clients may call the API. Login and signup
+// are limited per client IP, everything behind authentication per
+// account.
+type RateLimit struct {
+	Enabled bool  `yaml:"enabled" env:"RATE_LIMIT_ENABLED"`
+	Login   Limit `yaml:"login" env:"RATE_LIMIT_LOGIN"`
+	Signup  Limit `yaml:"signup" env:"RATE_LIMIT_SIGNUP"`
+	Writes  Limit `yaml:"writes" env:"RATE_LIMIT_WRITES"`
+	Reads   Limit `yaml:"reads" env:"RATE_LIMIT_READS"`
+}
+
+// Limit allows bursts of Requests, refilled at Requests per Per. Its
+// environment variables are prefixed by the section, e.g.
+// RATE_LIMIT_LOGIN_REQUESTS.
+type Limit struct {
+	Requests int           `yaml:"requests" env:"REQUESTS" validate:"gt=0"`
+	Per      time.Duration `yaml:"per" env:"PER" validate:"gt=0"`
+}
+
+// Auth configures login tokens.
+type Auth struct {
+	Secret string `yaml:"secret" env:"SECRET" secret:"true" validate:"required"`
+}
+
+// Database selects the database driver and tunes the connection pool.
+// Zero pool settings keep the database/sql defaults.
+type Database struct {
+	Driver          string        `yaml:"driver" env:"DB_DRIVER" validate:"oneof=postgres mysql sqlite"`
+	DSN             string        `yaml:"dsn" env:"DATABASE_STRING" secret:"true"`
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" validate:"gte=0"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" validate:"gte=0"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" validate:"gte=0"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" validate:"gte=0"`
+	// AutoMigrate creates the schema on start. In-memory SQLite always does.
+	AutoMigrate bool `yaml:"auto_migrate" env:"DB_AUTO_MIGRATE"`
+}
+
+// Storage selects where uploaded blobs are kept.
+type Storage struct {
+	Backend string `yaml:"backend" env:"BLOB_STORE" validate:"oneof=local s3"`
+	Dir     string `yaml:"dir" env:"BLOB_DIR"`
+	S3      S3     `yaml:"s3"`
+}
+
+// S3 configures an S3-compatible blob store.
+type S3 struct {
+	Endpoint  string `yaml:"endpoint" env:"S3_ENDPOINT"`
+	Region    string `yaml:"region" env:"S3_REGION"`
+	Bucket    string `yaml:"bucket" env:"S3_BUCKET"`
+	AccessKey string `yaml:"access_key" env:"S3_ACCESS_KEY" secret:"true"`
+	SecretKey string `yaml:"secret_key" env:"S3_SECRET_KEY" secret:"true"`
+	UseSSL    bool   `yaml:"use_ssl" env:"S3_USE_SSL"`
+}
+
+// Uploads limits attachments.
+type Uploads struct {
+	MaxBytes     int64    `yaml:"max_bytes" env:"UPLOAD_MAX_BYTES" validate:"gt=0"`
+	QuotaBytes   int64    `yaml:"quota_bytes" env:"UPLOAD_QUOTA_BYTES" validate:"gt=0"`
+	AllowedTypes []string `yaml:"allowed_types" env:"UPLOAD_ALLOWED_TYPES" validate:"min=1"`
+}
+
+// Imports limits post archive imports. Uploaded archives wait in
+// SpoolDir, the system temporary directory when empty, until their job
+// has run. MaxQueued limits the imports each account has queued or
+// running at once.
+type Imports struct {
+	MaxBytes  int64  `yaml:"max_bytes" env:"IMPORT_MAX_BYTES" validate:"gt=0"`
+	MaxQueued int    `yaml:"max_queued" env:"IMPORT_MAX_QUEUED" validate:"gt=0"`
+	SpoolDir  string `yaml:"spool_dir" env:"IMPORT_SPOOL_DIR"`
+}
+
+// Timeline selects how home timelines are built.
+type Timeline struct {
+	Strategy string `yaml:"strategy" env:"TIMELINE_STRATEGY" validate:"oneof=read write"`
+}
+
+// Feeds configures RSS and Atom feeds.
+type Feeds struct {
+	Items int `yaml:"items" env:"FEED_ITEMS" validate:"min=1,max=100"`
+}
+
+// Events configures the event stream.
+type Events struct {
+	Broker string `yaml:"broker" env:"EVENT_BROKER" validate:"oneof=memory postgres"`
+	// Replay is how many recent events are kept for resuming clients.
+	Replay int `yaml:"replay" env:"EVENT_REPLAY" validate:"gt=0"`
+}
+
+// Collab configures live editing.
+type Collab struct {
+	// SaveInterval is how often live edits are written back to the post.
+	SaveInterval   time.Duration `yaml:"save_interval" env:"COLLAB_SAVE_INTERVAL" validate:"gt=0"`
+	AllowedOrigins []string      `yaml:"allowed_origins" env:"COLLAB_ALLOWED_ORIGINS"`
+}
+
+// Trash configures how long deleted posts and accounts are kept.
+type Trash struct {
+	Retention time.Duration `yaml:"retention" env:"TRASH_RETENTION" validate:"gt=0"`
+}
+
+// Default returns the configuration used when no source sets a value.
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:              ":8080",
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       time.Minute,
+			WriteTimeout:      time.Minute,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Log:     Log{Level: "info", Format: "text"},
+		Metrics: Metrics{Enabled: true, Path: "/metrics"},
+		Tracing: Tracing{Exporter: "none", ServiceName: "genesis", SampleRatio: 1},
+		Health:  Health{Timeout: 2 * time.Second},
+		RateLimit: RateLimit{
+			Enabled: true,
+			Login:   Limit{Requests: 10, Per: time.Minute},
+			Signup:  Limit{Requests: 10, Per: time.Hour},
+			Writes:  Limit{Requests: 60, Per: time.Minute},
+			Reads:   Limit{Requests: 300, Per: time.Minute},
+		},
+		Database: Database{Driver: "postgres"},
+		Storage: Storage{
+			Backend: "local",
+			Dir:     "uploads",
+			S3:      S3{UseSSL: true},
+		},
+		Uploads: Uploads{
+			MaxBytes:     10 << 20,
+			QuotaBytes:   100 << 20,
+			AllowedTypes: []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf", "text/plain"},
+		},
+		Imports:   Imports{MaxBytes: 32 << 20, MaxQueued: 2},
+		Timeline:  Timeline{Strategy: "read"},
+		Feeds:     Feeds{Items: 20},
+		Events:    Events{Broker: "memory", Replay: 1024},
+		Collab:    Collab{SaveInterval: 5 * time.Second},
+		Trash:     Trash{Retention: 30 * 24 * time.Hour},
+		Reactions: []string{"like", "love", "laugh", "wow", "sad"},
+	}
+}
+
+// Validate reports every invalid setting, naming each by its path and
+// environment variable.
+func (cfg Config) Validate() error {
+	var errs []error
+	check := validator.New()
+	check.RegisterTagNameFunc(func(f reflect.StructField) string {
+		return f.Tag.Get("yaml")
+	})
+	if err := check.Struct(cfg); err != nil {
+		var fields validator.ValidationErrors
+		if !errors.As(err, &fields) {
+			return err
+		}
+		for _, field := range fields {
+			// Namespace is "Config.<path>", indexed for list entries
+			path := strings.TrimPrefix(field.Namespace(), "Config.")
+			errs = append(errs, fmt.Errorf("%s: %s", describe(path), problem(field)))
+		}
+	}
+
+	if cfg.Database.DSN == "" && cfg.Database.Driver != "sqlite" {
+		errs = append(errs, fmt.Errorf("%s: required for the %s driver", describe("database.dsn"), cfg.Database.Driver))
+	}
+	if (cfg.Server.TLS.CertFile == "") != (cfg.Server.TLS.KeyFile == "") {
+		errs = append(errs, fmt.Errorf("%s and %s: set both or neither", describe("server.tls.cert_file"), describe("server.tls.key_file")))
+	}
+	if cfg.Storage.Backend == "s3" && (cfg.Storage.S3.Endpoint == "" || cfg.Storage.S3.Bucket == "") {
+		errs = append(errs, fmt.Errorf("%s and %s: required for the s3 backend", describe("storage.s3.endpoint"), describe("storage.s3.bucket")))
+	}
+	if cfg.Events.Broker == "postgres" && cfg.Database.Driver != "postgres" {
+		errs = append(errs, fmt.Errorf("%s: the postgres broker needs the postgres database driver", describe("events.broker")))
+	}
+	return errors.Join(errs...)
+}
+
+// problem words a failed validation rule.
+func problem(field validator.FieldError) string {
+	param := field.Param()
+	switch field.Tag() {
+	case "required":
+		return "is required"
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(param), ", ") + fmt.Sprintf(", not %q", field.Value())
+	case "url":
+		return "must be an absolute URL"
+	case "cidr|ip":
+		return "must be an IP address or CIDR range"
+	case "min", "gte":
+		if field.Kind() == reflect.Slice {
+			return "needs at least " + param + " entries"
+		}
+		return "must be at least " + param
+	case "lte":
+		return "must be at most " + param
+	case "max":
+		if field.Kind() == reflect.String {
+			return "must be at most " + param + " characters"
+		}
+		return "must be at most " + param
+	case "gt":
+		return "must be greater than " + param
+	}
+	return "fails " + field.Tag()
+}
+
+// describe names a setting by its path and, when it has one, its
+// environment variable.
+func describe(path string) string {
+	base, _, _ := strings.Cut(path, "[")
+	for _, s := range settings(reflect.ValueOf(Config{})) {
+		if s.path == base && s.env != "" {
+			return path + " (" + s.env + ")"
+		}
+	}
+	return path
+}
+
+// String lists every setting with secrets redacted, so a Config can be
+// logged safely.
+func (cfg Config) String() string {
+	var b strings.Builder
+	for _, s := range settings(reflect.ValueOf(cfg)) {
+		b.WriteString(s.path)
+		b.WriteString(": ")
+		b.WriteString(s.display())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// LogValue lists every setting with secrets redacted, for structured logs.
+func (cfg Config) LogValue() slog.Value {
+	var attrs []slog.Attr
+	for _, s := range settings(reflect.ValueOf(cfg)) {
+		attrs = append(attrs, slog.String(s.path, s.display()))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// GoString redacts secrets from %#v as well.
+func (cfg Config) GoString() string {
+	return "config.Config{\n" + cfg.String() + "}"
+}
@@ -0,0 +1,262 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces the value of secret settings when printed.
+const redacted = "[redacted]"
+
+// Load builds the configuration from the defaults, then the file named by
+// -config or CONFIG_FILE, then the environment, then the flags in args,
+// and validates the result. A .env file in the working directory, if any,
+// is read into the environment first.
+func Load(args []string) (Config, error) {
+	cfg := Default()
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return cfg, fmt.Errorf("reading .env: %w", err)
+	}
+	all := settings(reflect.ValueOf(&cfg).Elem())
+
+	// Flags are parsed first to find the file but applied last
+	fs := flag.NewFlagSet("genesis", flag.ContinueOnError)
+	file := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML configuration `file` (CONFIG_FILE)")
+	flags := map[string]string{}
+	for _, s := range all {
+		usage := "sets " + s.path
+		if s.env != "" {
+			usage += " (" + s.env + ")"
+		}
+		fs.Func(s.path, usage, func(raw string) error {
+			flags[s.path] = raw
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *file != "" {
+		if err := loadFile(*file, all); err != nil {
+			return cfg, err
+		}
+	}
+
+	for _, s := range all {
+		if s.env == "" {
+			continue
+		}
+		if raw, ok := os.LookupEnv(s.env); ok {
+			if err := s.set(raw); err != nil {
+				return cfg, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+
+	for _, s := range all {
+		if raw, ok := flags[s.path]; ok {
+			if err := s.set(raw); err != nil {
+				return cfg, fmt.Errorf("-%s: %w", s.path, err)
+			}
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// loadFile applies the settings in a YAML or TOML file, picked by its
+// extension. Keys that name no setting are rejected so typos don't go
+// unnoticed.
+func loadFile(path string, all []setting) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	tree := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.NewDecoder(bytes.NewReader(data)).Decode(&tree)
+	default:
+		return fmt.Errorf("config file %s: unknown format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	values := map[string]interface{}{}
+	flatten("", tree, values)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		s, ok := find(all, key)
+		if !ok {
+			errs = append(errs, fmt.Errorf("config file %s: unknown setting %s", path, key))
+			continue
+		}
+		if err := s.setValue(values[key]); err != nil {
+			errs = append(errs, fmt.Errorf("config file %s: %s: %w", path, key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// flatten turns nested tables into dotted keys.
+func flatten(prefix string, tree map[string]interface{}, out map[string]interface{}) {
+	for key, value := range tree {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			flatten(key, nested, out)
+			continue
+		}
+		out[key] = value
+	}
+}
+
+func find(all []setting, path string) (setting, bool) {
+	for _, s := range all {
+		if s.path == path {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+// setting is one leaf of Config.
+type setting struct {
+	path   string
+	env    string
+	secret bool
+	value  reflect.Value
+}
+
+// settings lists the leaves of the Config in v, in declaration order.
+func settings(v reflect.Value) []setting {
+	var out []setting
+	// A struct field's env tag prefixes the variables of its fields, so
+	// one struct type can be used for several sections.
+	var walk func(prefix, envPrefix string, v reflect.Value)
+	walk = func(prefix, envPrefix string, v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			path := field.Tag.Get("yaml")
+			if prefix != "" {
+				path = prefix + "." + path
+			}
+			env := field.Tag.Get("env")
+			if env != "" && envPrefix != "" {
+				env = envPrefix + "_" + env
+			}
+			if field.Type.Kind() == reflect.Struct {
+				walk(path, env, v.Field(i))
+				continue
+			}
+			out = append(out, setting{
+				path:   path,
+				env:    env,
+				secret: field.Tag.Get("secret") == "true",
+				value:  v.Field(i),
+			})
+		}
+	}
+	walk("", "", v)
+	return out
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// set parses raw, as found in the environment or on the command line.
+// Lists are comma separated.
+func (s setting) set(raw string) error {
+	v := s.value
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int || v.Kind() == reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Slice:
+		var list []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// setValue applies a value decoded from a file. Lists may be given as
+// lists or as comma-separated strings; everything else goes through set.
+func (s setting) setValue(value interface{}) error {
+	if items, ok := value.([]interface{}); ok {
+		if s.value.Kind() != reflect.Slice {
+			return errors.New("expected a single value, not a list")
+		}
+		list := make([]string, len(items))
+		for i, item := range items {
+			list[i] = fmt.Sprint(item)
+		}
+		s.value.Set(reflect.ValueOf(list))
+		return nil
+	}
+	return s.set(fmt.Sprint(value))
+}
+
+// display renders the value for String, hiding secrets that are set.
+func (s setting) display() string {
+	if s.secret && !s.value.IsZero() {
+		return redacted
+	}
+	if s.value.Kind() == reflect.Slice {
+		return strings.Join(s.value.Interface().([]string), ",")
+	}
+	return fmt.Sprint(s.value.Interface())
+}
@@ -1,23 +1,27 @@
 package controllers
 
 import (
-	"genesis/initializers"
-	"genesis/models"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
+	"genesis/metrics"
+	"genesis/models"
+	"genesis/problem"
+	"genesis/store"
+
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm"
 )
 
 type AccountBody struct {
 	Email    string `json:"email" binding:"required,email,max=255"`
 	Password string `json:"password" binding:"required,min=8,max=255"`
+	// Handle is optional on signup and derived from the email when missing
+	Handle string `json:"handle" binding:"omitempty,min=3,max=32,alphanum"`
 }
 
 type EmailChange struct {
@@ -27,18 +31,17 @@ type EmailChange struct {
 type AccountResponse struct {
 	ID        uint           `json:"id"`
 	Email     string         `json:"email"`
+	Handle    string         `json:"handle"`
 	CreatedAt time.Time      `json:"created_at"`
 	Posts     []ResponsePost `json:"posts"`
 	UpdatedAt time.Time      `json:"updated_at"`
 }
 
-func AccountCreate(c *gin.Context) {
+func (s *Server) AccountCreate(c *gin.Context) {
 	// Clean and Get response Body
 	var req AccountBody
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid Request Object",
-		})
+		c.Error(problem.Invalid(err))
 		return
 	}
 
@@ -46,42 +49,50 @@ func AccountCreate(c *gin.Context) {
 	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
 	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), 10)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Unable to Hash Password",
-		})
+		c.Error(problem.New(problem.Internal, "Unable to create account"))
 		return
 	}
 
 	// Check and validate email uniqueness
-	var existingAccount models.Account
-	if err := initializers.DB.Where("email = ?", req.Email).First(&existingAccount).Error; err == nil {
-		log.Printf("Account already exists for email: %s", req.Email)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Email Already Exists",
-		})
+	accounts := s.store(c).Accounts()
+	if _, err := accounts.GetByEmail(req.Email); err == nil {
+		slog.InfoContext(c.Request.Context(), "Account already exists", "email", req.Email)
+		c.Error(problem.New(problem.EmailTaken, "Email already used"))
 		return
-	} else if err != gorm.ErrRecordNotFound {
-		log.Printf("Error checking existing account: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error checking account",
-		})
+	} else if !errors.Is(err, store.ErrNotFound) {
+		slog.ErrorContext(c.Request.Context(), "Error checking existing account", "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to create account"))
+		return
+	}
+
+	// Pick the requested handle or derive a free one
+	handle := strings.ToLower(req.Handle)
+	if handle != "" {
+		if taken, _ := accounts.HandleTaken(handle); taken {
+			c.Error(problem.New(problem.HandleTaken, "Handle already taken"))
+			return
+		}
+	} else if handle, err = accounts.FreeHandle(req.Email); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error deriving handle", "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to create account"))
 		return
 	}
 
 	account := models.Account{
 		Email:    req.Email,
+		Handle:   handle,
 		Password: string(hash),
 	}
 
-	if err := initializers.DB.Create(&account).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error Creating Account",
-		})
+	if err := accounts.Create(&account); err != nil {
+		c.Error(problem.New(problem.Internal, "Unable to create account"))
 		return
 	}
+	metrics.Signups.Inc()
 	resp := AccountResponse{
 		ID:        account.ID,
 		Email:     account.Email,
+		Handle:    account.Handle,
 		CreatedAt: account.CreatedAt,
 		UpdatedAt: account.UpdatedAt,
 	}
@@ -90,32 +101,28 @@ func AccountCreate(c *gin.Context) {
 	})
 }
 
-func AccountLogin(c *gin.Context) {
+func (s *Server) AccountLogin(c *gin.Context) {
 	// Get and Sanitize the input request
 	var req AccountBody
 	if err := c.ShouldBindJSON(&req); err != nil {
-		log.Printf("Invalid request Object")
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request Object",
-		})
+		slog.InfoContext(c.Request.Context(), "Invalid request Object")
+		c.Error(problem.Invalid(err))
 		return
 	}
 
 	// Fetch user data and compare password hash
 
-	var existingAccount models.Account
-	if err := initializers.DB.Where("email = ?", req.Email).First(&existingAccount).Error; err != nil {
-		log.Println("Account Does not exist")
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid Login",
-		})
+	existingAccount, err := s.store(c).Accounts().GetByEmail(req.Email)
+	if err != nil {
+		slog.InfoContext(c.Request.Context(), "Account Does not exist")
+		metrics.Logins.WithLabelValues("failure").Inc()
+		c.Error(problem.New(problem.InvalidCredentials, "Incorrect email or password"))
 		return
 	}
-	err := bcrypt.CompareHashAndPassword([]byte(existingAccount.Password), []byte(req.Password))
+	err = bcrypt.CompareHashAndPassword([]byte(existingAccount.Password), []byte(req.Password))
 	if err != nil { // If err != nil then password incorrect
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Incorrect Login",
-		})
+		metrics.Logins.WithLabelValues("failure").Inc()
+		c.Error(problem.New(problem.InvalidCredentials, "Incorrect email or password"))
 		return
 	}
 	// Correct password
@@ -124,17 +131,15 @@ func AccountLogin(c *gin.Context) {
 		"sub": existingAccount.ID,
 		"exp": time.Now().Add(time.Hour * 24 * 30).Unix(),
 	})
-	//log.Printf("Token: %v", token)
 	// Sign and get the complete encoded token as string using secret
-	tokenString, err := token.SignedString([]byte(os.Getenv("SECRET")))
+	tokenString, err := token.SignedString([]byte(s.cfg.Auth.Secret))
 
 	if err != nil {
-		log.Printf("Unable to sign jwt token with secret %s", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Something Unexpected Happened",
-		})
+		slog.ErrorContext(c.Request.Context(), "Unable to sign jwt token with secret", "error", err)
+		c.Error(problem.New(problem.Internal, "Something unexpected happened"))
 		return
 	}
+	metrics.Logins.WithLabelValues("success").Inc()
 	c.SetSameSite(http.SameSiteLaxMode)
 	c.SetCookie("Authorization", tokenString, 3600*24*30, "", "", false, true)
 	c.JSON(http.StatusOK, gin.H{
@@ -143,18 +148,18 @@ func AccountLogin(c *gin.Context) {
 
 }
 
-func AccountDetail(c *gin.Context) {
+func (s *Server) AccountDetail(c *gin.Context) {
 	// Get user from jwt auth
 	accountID, ok := c.Get("accountID")
 
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "No user associated in jwt"})
+		c.Error(problem.New(problem.Unauthenticated, "User is unauthenticated"))
 		return
 	}
 
-	var account models.Account
-	if err := initializers.DB.Preload("Posts").First(&account, accountID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Associated user not found"})
+	account, err := s.store(c).Accounts().GetWithPosts(accountID.(uint))
+	if err != nil {
+		c.Error(problem.New(problem.NotFound, "Account not found"))
 		return
 	}
 
@@ -171,6 +176,7 @@ func AccountDetail(c *gin.Context) {
 	resp := AccountResponse{
 		ID:        account.ID,
 		Email:     account.Email,
+		Handle:    account.Handle,
 		CreatedAt: account.CreatedAt,
 		Posts:     responsePosts,
 		UpdatedAt: account.UpdatedAt,
@@ -181,47 +187,48 @@ func AccountDetail(c *gin.Context) {
 
 }
 
-func AccountUpdate(c *gin.Context) {
+func (s *Server) AccountUpdate(c *gin.Context) {
 	// Get auth user account
 	accountID, err := c.Get("accountID")
 	if !err {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not associated with jwt"})
+		c.Error(problem.New(problem.Unauthenticated, "User is unauthenticated"))
 		return
 	}
 	var req EmailChange
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Request Body"})
+		c.Error(problem.Invalid(err))
 		return
 	}
-	var existingAccount models.Account
-	if err := initializers.DB.Where("email = ?", req.Email).First(&existingAccount).Error; err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Email already used"})
+	accounts := s.store(c).Accounts()
+	if _, err := accounts.GetByEmail(req.Email); err == nil {
+		c.Error(problem.New(problem.EmailTaken, "Email already used"))
 		return
 	}
 
-	var account models.Account
-	if err := initializers.DB.First(&account, accountID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch account"})
-		return
-	}
-
-	if err := initializers.DB.Model(&account).Update("email", req.Email).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to Update Account"})
+	if err := accounts.UpdateEmail(accountID.(uint), req.Email); err != nil {
+		c.Error(problem.New(problem.Internal, "Unable to update account"))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Account Updated"})
 }
 
-func AccountDelete(c *gin.Context) {
+func (s *Server) AccountDelete(c *gin.Context) {
 	// Get Account ID jwt
 	accountID, ok := c.Get("accountID")
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "No account associated with jwt"})
+		c.Error(problem.New(problem.Unauthenticated, "User is unauthenticated"))
 		return
 	}
-	if err := initializers.DB.Delete(&models.Account{}, accountID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to delete account"})
+	postIDs, err := s.store(c).Accounts().Delete(accountID.(uint))
+	if err != nil {
+		c.Error(problem.New(problem.Internal, "Unable to delete account"))
 		return
 	}
+	for _, id := range postIDs {
+		s.unindexPost(id)
+		if err := s.Timeline.PostRemoved(id); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to remove post from timelines", "post_id", id, "error", err)
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Account Deleted"})
 }
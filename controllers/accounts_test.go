@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"genesis/models"
+)
+
+// assertPostsHidden checks that post is gone from every public path:
+// the post itself, its permalink, the viewer's timeline and the site feed.
+func (a *apiServer) assertPostsHidden(viewer uint, post ResponsePost) {
+	a.t.Helper()
+	a.doJSON(viewer, http.MethodGet, postPath(post.ID), "", http.StatusNotFound, nil)
+	a.doJSON(viewer, http.MethodGet, "/authors/alice/posts/"+post.Slug, "", http.StatusNotFound, nil)
+
+	var timeline struct{ Posts []ResponsePost }
+	a.doJSON(viewer, http.MethodGet, "/timeline/", "", http.StatusOK, &timeline)
+	if len(timeline.Posts) != 0 {
+		a.t.Fatalf("timeline = %d posts, want none of the deleted account's", len(timeline.Posts))
+	}
+	w := a.doJSON(viewer, http.MethodGet, "/feed/rss", "", http.StatusOK, nil)
+	if strings.Contains(w.Body.String(), post.Title) {
+		a.t.Fatal("site feed lists a post of a deleted account")
+	}
+}
+
+func TestSQLiteAccountDeleteHidesPosts(t *testing.T) {
+	for _, strategy := range []string{"read", "write"} {
+		t.Run(strategy, func(t *testing.T) {
+			a := newAPIServer(t, strategy)
+			alice, bob := a.account("alice"), a.account("bob")
+			a.doJSON(bob, http.MethodPost, "/accounts/alice/follow", "", http.StatusOK, nil)
+			post := a.createPost(alice, `{"title": "Farewell", "body": "goodbye everyone"}`)
+
+			a.doJSON(alice, http.MethodDelete, "/account/", "", http.StatusOK, nil)
+			a.assertPostsHidden(bob, post)
+
+			var search struct{ Total int64 }
+			a.doJSON(bob, http.MethodGet, "/search/?q=goodbye", "", http.StatusOK, &search)
+			if search.Total != 0 {
+				t.Fatalf("search hits = %d, want none of the deleted account's", search.Total)
+			}
+			if n := a.count(&models.Post{}, "id = ? AND deleted_at IS NOT NULL", post.ID); n != 1 {
+				t.Fatal("post of the deleted account not moved to the trash")
+			}
+		})
+	}
+}
+
+// Accounts deleted before their posts were trashed along with them still
+// have live posts; reads leave those out by their author.
+func TestSQLiteDeletedAuthorFilter(t *testing.T) {
+	for _, strategy := range []string{"read", "write"} {
+		t.Run(strategy, func(t *testing.T) {
+			a := newAPIServer(t, strategy)
+			alice, bob := a.account("alice"), a.account("bob")
+			a.doJSON(bob, http.MethodPost, "/accounts/alice/follow", "", http.StatusOK, nil)
+			post := a.createPost(alice, `{"title": "Farewell", "body": "goodbye everyone"}`)
+
+			if err := a.db.Delete(&models.Account{}, alice).Error; err != nil {
+				t.Fatal(err)
+			}
+			a.assertPostsHidden(bob, post)
+		})
+	}
+}
@@ -0,0 +1,479 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"genesis/archive"
+	"genesis/metrics"
+	"genesis/models"
+	"genesis/problem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Import job states stored on models.ImportJob.
+const (
+	importPending = "pending"
+	importRunning = "running"
+	importDone    = "done"
+	importFailed  = "failed"
+)
+
+// importProgressEvery is how many items are imported between progress saves.
+const importProgressEvery = 50
+
+// ResponseImport represents the progress of an import job.
+type ResponseImport struct {
+	ID        uint                 `json:"id"`
+	Format    string               `json:"format"`
+	Status    string               `json:"status"`
+	Total     int                  `json:"total"`
+	Imported  int                  `json:"imported"`
+	Failed    int                  `json:"failed"`
+	Errors    []models.ImportError `json:"errors"`
+	CreatedAt time.Time            `json:"created_at"`
+	UpdatedAt time.Time            `json:"updated_at"`
+}
+
+func newResponseImport(job models.ImportJob) ResponseImport {
+	errs := job.Errors
+	if errs == nil {
+		errs = []models.ImportError{}
+	}
+	return ResponseImport{
+		ID:        job.ID,
+		Format:    job.Format,
+		Status:    job.Status,
+		Total:     job.Total,
+		Imported:  job.Imported,
+		Failed:    job.Failed,
+		Errors:    errs,
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+	}
+}
+
+// importTask names a queued import job. Its archive waits in the spool
+// directory, so queued imports hold none of their posts in memory.
+type importTask struct {
+	jobID     uint
+	accountID uint
+}
+
+// AccountExport handles GET requests to download all of the caller's posts,
+// streamed as a zip of Markdown files (?format=markdown, the default) or
+// as JSON lines (?format=jsonl).
+func (s *Server) AccountExport(c *gin.Context) {
+	accountID := c.GetUint("accountID")
+	format := c.DefaultQuery("format", archive.FormatMarkdown)
+
+	account, err := s.store(c).Accounts().Get(accountID)
+	if err != nil {
+		c.Error(problem.New(problem.NotFound, "Account not found"))
+		return
+	}
+
+	contentType, ext := "application/zip", "zip"
+	if format == archive.FormatJSONL {
+		contentType, ext = "application/x-ndjson", "jsonl"
+	}
+	w, err := archive.NewWriter(c.Writer, format)
+	if err != nil {
+		c.Error(problem.New(problem.BadRequest, "Unknown export format"))
+		return
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-posts.%s"`, account.Handle, ext))
+	c.Status(http.StatusOK)
+
+	// The status line is already out, so failures from here on can only
+	// cut the stream short.
+	err = s.store(c).Posts().Each(accountID, func(post models.Post) error {
+		return w.Add(archivePost(post))
+	})
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to export posts", "error", err)
+	}
+}
+
+func archivePost(post models.Post) archive.Post {
+	return archive.Post{
+		Title:     post.Title,
+		Slug:      post.Slug,
+		Format:    post.Format,
+		Draft:     post.Draft,
+		Tags:      tagSlugs(post.Tags),
+		CreatedAt: post.CreatedAt,
+		UpdatedAt: post.UpdatedAt,
+		Body:      post.Body,
+	}
+}
+
+// AccountImport handles POST requests that upload an archive in either
+// export format as the multipart "file" field. Posts are created by a
+// background job whose progress is reported by ImportGet.
+func (s *Server) AccountImport(c *gin.Context) {
+	accountID := c.GetUint("accountID")
+	if !s.reserveImport(accountID) {
+		c.Error(problem.New(problem.RateLimited, "Too many imports in progress"))
+		return
+	}
+	queued := false
+	defer func() {
+		if !queued {
+			s.releaseImport(accountID)
+		}
+	}()
+	maxBytes := s.cfg.Imports.MaxBytes
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+1<<20)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.Error(problem.New(problem.PayloadTooLarge, "File too large"))
+			return
+		}
+		c.Error(problem.New(problem.BadRequest, "Missing file"))
+		return
+	}
+	if header.Size > maxBytes {
+		c.Error(problem.New(problem.PayloadTooLarge, "File too large"))
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.Error(problem.New(problem.BadRequest, "Unreadable file"))
+		return
+	}
+	defer file.Close()
+	spool, size, err := s.spoolArchive(file)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to spool import archive", "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to start import"))
+		return
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	items, err := archive.Read(spool, size)
+	if errors.Is(err, archive.ErrTooManyItems) {
+		c.Error(problem.New(problem.PayloadTooLarge, "Too many posts in archive"))
+		return
+	}
+	if err != nil {
+		c.Error(problem.New(problem.BadRequest, "Unreadable archive"))
+		return
+	}
+	if len(items) == 0 {
+		c.Error(problem.New(problem.BadRequest, "Archive holds no posts"))
+		return
+	}
+
+	format := archive.FormatJSONL
+	head := make([]byte, 2)
+	if _, err := spool.ReadAt(head, 0); err == nil && string(head) == "PK" {
+		format = archive.FormatMarkdown
+	}
+	job := models.ImportJob{
+		AccountID: accountID,
+		Format:    format,
+		Status:    importPending,
+		Total:     len(items),
+		Errors:    []models.ImportError{},
+	}
+	if err := s.store(c).Imports().Create(&job); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to create import job", "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to start import"))
+		return
+	}
+	if err := os.Rename(spool.Name(), s.spoolPath(job.ID)); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to spool import archive", "job_id", job.ID, "error", err)
+		s.failImport(job.ID)
+		c.Error(problem.New(problem.Internal, "Unable to start import"))
+		return
+	}
+
+	s.importsOnce.Do(func() {
+		s.importsDone.Add(1)
+		go func() {
+			defer s.importsDone.Done()
+			for {
+				select {
+				case <-s.closing:
+					return
+				case task := <-s.imports:
+					s.runImport(task)
+				}
+			}
+		}()
+	})
+	select {
+	case s.imports <- importTask{jobID: job.ID, accountID: accountID}:
+		queued = true
+	default:
+		s.failImport(job.ID)
+		os.Remove(s.spoolPath(job.ID))
+		c.Error(problem.New(problem.Unavailable, "Too many imports in progress"))
+		return
+	}
+
+	c.Header("Location", "/account/imports/"+strconv.FormatUint(uint64(job.ID), 10))
+	c.JSON(http.StatusAccepted, gin.H{"import": newResponseImport(job)})
+}
+
+// spoolArchive copies an uploaded archive into a new file in the spool
+// directory and returns it with its size.
+func (s *Server) spoolArchive(r io.Reader) (*os.File, int64, error) {
+	if err := os.MkdirAll(s.spoolDir(), 0o700); err != nil {
+		return nil, 0, err
+	}
+	spool, err := os.CreateTemp(s.spoolDir(), "upload-*")
+	if err != nil {
+		return nil, 0, err
+	}
+	size, err := io.Copy(spool, r)
+	if err != nil {
+		spool.Close()
+		os.Remove(spool.Name())
+		return nil, 0, err
+	}
+	return spool, size, nil
+}
+
+// spoolDir is where uploaded archives wait for their import job.
+func (s *Server) spoolDir() string {
+	if s.cfg.Imports.SpoolDir != "" {
+		return s.cfg.Imports.SpoolDir
+	}
+	return filepath.Join(os.TempDir(), "genesis-imports")
+}
+
+// spoolPath is the file holding the archive of import job id.
+func (s *Server) spoolPath(id uint) string {
+	return filepath.Join(s.spoolDir(), "import-"+strconv.FormatUint(uint64(id), 10))
+}
+
+// reserveImport takes one of the import slots of accountID, reporting
+// false when the account already has Imports.MaxQueued imports queued or
+// running.
+func (s *Server) reserveImport(accountID uint) bool {
+	s.importsMu.Lock()
+	defer s.importsMu.Unlock()
+	if s.importsQueued[accountID] >= s.cfg.Imports.MaxQueued {
+		return false
+	}
+	s.importsQueued[accountID]++
+	return true
+}
+
+func (s *Server) releaseImport(accountID uint) {
+	s.importsMu.Lock()
+	defer s.importsMu.Unlock()
+	if s.importsQueued[accountID]--; s.importsQueued[accountID] <= 0 {
+		delete(s.importsQueued, accountID)
+	}
+}
+
+// failImport marks an import job that never started as failed.
+func (s *Server) failImport(id uint) {
+	if err := s.Store.Imports().SetStatus(id, importFailed); err != nil {
+		slog.Error("Failed to save import job", "job_id", id, "error", err)
+	}
+}
+
+// ImportGet handles GET requests for the progress and per-item errors of
+// one of the caller's imports.
+func (s *Server) ImportGet(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(problem.New(problem.BadRequest, "Invalid import ID"))
+		return
+	}
+	job, err := s.store(c).Imports().Get(c.GetUint("accountID"), uint(id))
+	if err != nil {
+		c.Error(problem.New(problem.NotFound, "Import not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"import": newResponseImport(job)})
+}
+
+// runImport creates the posts of one import, validating every item with
+// the same rules as PostsCreate, and removes its spooled archive.
+func (s *Server) runImport(task importTask) {
+	path := s.spoolPath(task.jobID)
+	defer func() {
+		os.Remove(path)
+		s.releaseImport(task.accountID)
+	}()
+	imports := s.Store.Imports()
+	job, err := imports.Load(task.jobID)
+	if err != nil {
+		slog.Error("Failed to load import job", "job_id", task.jobID, "error", err)
+		return
+	}
+	items, err := readSpooled(path)
+	os.Remove(path)
+	if err != nil {
+		slog.Error("Failed to read import archive", "job_id", job.ID, "error", err)
+		job.Status = importFailed
+		job.Errors = append(job.Errors, models.ImportError{Error: "Archive could not be read"})
+		if err := imports.Save(&job); err != nil {
+			slog.Error("Failed to save import job", "job_id", job.ID, "error", err)
+		}
+		return
+	}
+	job.Status = importRunning
+	if err := imports.Save(&job); err != nil {
+		slog.Error("Failed to save import job", "job_id", job.ID, "error", err)
+	}
+
+	for i, item := range items {
+		select {
+		case <-s.closing:
+			job.Status = importFailed
+			job.Errors = append(job.Errors, models.ImportError{Error: "Interrupted by server shutdown"})
+			if err := imports.Save(&job); err != nil {
+				slog.Error("Failed to save import job", "job_id", job.ID, "error", err)
+			}
+			return
+		default:
+		}
+		if err := s.importItem(context.Background(), job.AccountID, item); err != nil {
+			job.Failed++
+			job.Errors = append(job.Errors, models.ImportError{Item: item.Name, Error: err.Error()})
+		} else {
+			job.Imported++
+		}
+		if (i+1)%importProgressEvery == 0 {
+			if err := imports.Save(&job); err != nil {
+				slog.Error("Failed to save import job", "job_id", job.ID, "error", err)
+			}
+		}
+	}
+
+	job.Status = importDone
+	if job.Imported == 0 {
+		job.Status = importFailed
+	}
+	if err := imports.Save(&job); err != nil {
+		slog.Error("Failed to save import job", "job_id", job.ID, "error", err)
+	}
+}
+
+// readSpooled reads the items of a spooled archive.
+func readSpooled(path string) ([]archive.Item, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return archive.Read(f, info.Size())
+}
+
+// FailInterruptedImports marks the imports a previous process left
+// pending or running as failed and removes the archives they left in the
+// spool directory. Call it once before serving; like the search index,
+// it assumes a single instance.
+func (s *Server) FailInterruptedImports() error {
+	failed, err := s.Store.Imports().MoveStatus([]string{importPending, importRunning}, importFailed)
+	if failed > 0 {
+		slog.Warn("Failed interrupted imports", "count", failed)
+	}
+	if err != nil {
+		return err
+	}
+	var spooled []string
+	for _, pattern := range []string{"upload-*", "import-*"} {
+		matches, _ := filepath.Glob(filepath.Join(s.spoolDir(), pattern))
+		spooled = append(spooled, matches...)
+	}
+	for _, path := range spooled {
+		if err := os.Remove(path); err != nil {
+			slog.Warn("Failed to remove spooled import archive", "path", path, "error", err)
+		}
+	}
+	return nil
+}
+
+// failQueuedImports marks the imports that never started as failed, so
+// they do not look pending forever.
+func (s *Server) failQueuedImports() {
+	for {
+		select {
+		case task := <-s.imports:
+			s.failImport(task.jobID)
+			os.Remove(s.spoolPath(task.jobID))
+			s.releaseImport(task.accountID)
+		default:
+			return
+		}
+	}
+}
+
+func (s *Server) importItem(ctx context.Context, accountID uint, item archive.Item) error {
+	if item.Err != nil {
+		return item.Err
+	}
+	req := RequestPostBody{
+		Title:  item.Post.Title,
+		Body:   item.Post.Body,
+		Tags:   item.Post.Tags,
+		Draft:  &item.Post.Draft,
+		Format: item.Post.Format,
+	}
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		return validationError(err)
+	}
+
+	post := models.Post{
+		Title:     req.Title,
+		Body:      req.Body,
+		Format:    req.Format,
+		AccountID: accountID,
+		Draft:     item.Post.Draft,
+	}
+	post.CreatedAt = item.Post.CreatedAt
+	post.UpdatedAt = item.Post.UpdatedAt
+	if err := s.createPost(ctx, &post, req.Tags, nil); err != nil {
+		if errors.Is(err, errUnrenderable) {
+			return err
+		}
+		slog.Error("Failed to import post", "account_id", accountID, "error", err)
+		return errors.New("unable to store post")
+	}
+	metrics.PostsCreated.WithLabelValues("import").Inc()
+	return nil
+}
+
+// validationError turns validator output into a short message naming
+// each failed field, worded like the API's validation problems.
+func validationError(err error) error {
+	p := problem.Invalid(err)
+	if len(p.Fields) == 0 {
+		return err
+	}
+	msgs := make([]string, len(p.Fields))
+	for i, field := range p.Fields {
+		msgs[i] = field.Field + ": " + field.Message
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"genesis/archive"
+)
+
+// importArchive uploads data as accountID and waits for the import job
+// to finish.
+func (a *apiServer) importArchive(accountID uint, data []byte) ResponseImport {
+	a.t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "archive")
+	part.Write(data)
+	form.Close()
+
+	w := a.do(accountID, http.MethodPost, "/account/import", &body, map[string]string{"Content-Type": form.FormDataContentType()})
+	if w.Code != http.StatusAccepted {
+		a.t.Fatalf("import = %d, want 202: %s", w.Code, w.Body)
+	}
+	location := w.Header().Get("Location")
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		var resp struct{ Import ResponseImport }
+		a.doJSON(accountID, http.MethodGet, location, "", http.StatusOK, &resp)
+		if resp.Import.Status == importDone || resp.Import.Status == importFailed {
+			return resp.Import
+		}
+	}
+	a.t.Fatal("import did not finish")
+	return ResponseImport{}
+}
+
+// postSummaries lists accountID's own posts as sorted one-line summaries.
+func (a *apiServer) postSummaries(accountID uint) []string {
+	a.t.Helper()
+	var resp struct{ Posts []ResponsePost }
+	a.doJSON(accountID, http.MethodGet, "/posts/", "", http.StatusOK, &resp)
+	summaries := make([]string, len(resp.Posts))
+	for i, post := range resp.Posts {
+		summaries[i] = fmt.Sprintf("%s|%s|%s|%v|%v", post.Title, post.Body, post.Format, post.Draft, post.Tags)
+	}
+	sort.Strings(summaries)
+	return summaries
+}
+
+func TestSQLiteArchiveRoundTrip(t *testing.T) {
+	for _, format := range []string{archive.FormatMarkdown, archive.FormatJSONL} {
+		t.Run(format, func(t *testing.T) {
+			a := newAPIServer(t, "read")
+			alice, bob := a.account("alice"), a.account("bob")
+			a.createPost(alice, `{"title": "Plain", "body": "just text", "tags": ["notes"]}`)
+			a.createPost(alice, `{"title": "Rich", "body": "# Heading\n\n*emphasis*", "format": "markdown", "tags": ["go", "notes"]}`)
+			a.createPost(alice, `{"title": "Unfinished", "body": "later", "draft": true}`)
+
+			w := a.doJSON(alice, http.MethodGet, "/account/export?format="+format, "", http.StatusOK, nil)
+			job := a.importArchive(bob, w.Body.Bytes())
+			if job.Status != importDone || job.Imported != 3 || job.Failed != 0 {
+				t.Fatalf("import = %+v, want 3 posts imported", job)
+			}
+			want, got := a.postSummaries(alice), a.postSummaries(bob)
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Fatalf("imported posts:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+			}
+		})
+	}
+}
+
+func TestSQLiteArchiveItemErrors(t *testing.T) {
+	a := newAPIServer(t, "read")
+	alice := a.account("alice")
+	data := `{"title": "Kept", "body": "fine"}` + "\n" +
+		`{"title": "Huge", "body": "` + strings.Repeat("x", 2<<20) + `"}` + "\n" +
+		`not json` + "\n" +
+		`{"title": "Also kept", "body": "fine"}` + "\n"
+
+	job := a.importArchive(alice, []byte(data))
+	if job.Status != importDone || job.Imported != 2 || job.Failed != 2 {
+		t.Fatalf("import = %+v, want 2 imported and 2 failed", job)
+	}
+	if job.Errors[0].Item != "line 2" || !strings.Contains(job.Errors[0].Error, "larger than") {
+		t.Fatalf("first error = %+v, want line 2 reported as too large", job.Errors[0])
+	}
+}
+
+func TestSQLiteImportQueue(t *testing.T) {
+	a := newAPIServer(t, "read")
+	alice, bob, carol := a.account("alice"), a.account("bob"), a.account("carol")
+	data := []byte(`{"title": "Kept", "body": "fine"}` + "\n")
+
+	job := a.importArchive(alice, data)
+	if job.Status != importDone {
+		t.Fatalf("import = %+v, want done", job)
+	}
+	if spooled, _ := os.ReadDir(a.srv.spoolDir()); len(spooled) != 0 {
+		t.Fatalf("%d archives left in the spool directory after the import", len(spooled))
+	}
+
+	// Once an account has its imports queued, it waits; others don't
+	for range a.srv.cfg.Imports.MaxQueued {
+		if !a.srv.reserveImport(carol) {
+			t.Fatal("import slot refused below the limit")
+		}
+	}
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "archive")
+	part.Write(data)
+	form.Close()
+	w := a.do(carol, http.MethodPost, "/account/import", &body, map[string]string{"Content-Type": form.FormDataContentType()})
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("import over the per-account limit = %d, want 429", w.Code)
+	}
+	if job := a.importArchive(bob, data); job.Status != importDone {
+		t.Fatalf("other account's import = %+v, want done", job)
+	}
+}
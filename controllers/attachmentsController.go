@@ -0,0 +1,355 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"genesis/imaging"
+	"genesis/models"
+	"genesis/problem"
+	"genesis/store"
+	"genesis/workers"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+)
+
+// ResponseAttachment represents the response structure for an attachment.
+// Images additionally list their generated variants once ready.
+type ResponseAttachment struct {
+	ID            uint                       `json:"id"`
+	Filename      string                     `json:"filename"`
+	MIMEType      string                     `json:"mime_type"`
+	Size          int64                      `json:"size"`
+	Hash          string                     `json:"hash"`
+	URL           string                     `json:"url"`
+	VariantStatus string                     `json:"variant_status,omitempty"`
+	Variants      map[string]ResponseVariant `json:"variants,omitempty"`
+	CreatedAt     time.Time                  `json:"created_at"`
+}
+
+// ResponseVariant represents one generated rendition of an image attachment.
+type ResponseVariant struct {
+	URL      string `json:"url"`
+	MIMEType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+func newResponseAttachment(a models.Attachment) ResponseAttachment {
+	resp := ResponseAttachment{
+		ID:            a.ID,
+		Filename:      a.Filename,
+		MIMEType:      a.MIMEType,
+		Size:          a.Size,
+		Hash:          a.Hash,
+		URL:           fmt.Sprintf("/attachments/%d", a.ID),
+		VariantStatus: a.VariantStatus,
+		CreatedAt:     a.CreatedAt,
+	}
+	if len(a.Variants) > 0 {
+		resp.Variants = map[string]ResponseVariant{}
+		for _, v := range a.Variants {
+			resp.Variants[v.Name] = ResponseVariant{
+				URL:      fmt.Sprintf("/attachments/%d?variant=%s", a.ID, v.Name),
+				MIMEType: v.MIMEType,
+				Size:     v.Size,
+				Width:    v.Width,
+				Height:   v.Height,
+			}
+		}
+	}
+	return resp
+}
+
+func newResponseAttachments(attachments []models.Attachment) []ResponseAttachment {
+	resps := make([]ResponseAttachment, len(attachments))
+	for i, a := range attachments {
+		resps[i] = newResponseAttachment(a)
+	}
+	return resps
+}
+
+// uploadTypeAllowed reports whether a sniffed MIME type is on the allowlist.
+func (s *Server) uploadTypeAllowed(mimeType string) bool {
+	for _, t := range s.cfg.Uploads.AllowedTypes {
+		if t == mimeType {
+			return true
+		}
+	}
+	return false
+}
+
+// AttachmentUpload handles multipart POST requests carrying a "file" field.
+// The content type is sniffed rather than trusted, and identical content
+// is stored once.
+func (s *Server) AttachmentUpload(c *gin.Context) {
+	accountID, exists := c.Get("accountID")
+	if !exists {
+		c.Error(problem.New(problem.Unauthenticated, "User is unauthenticated"))
+		return
+	}
+	maxBytes := s.cfg.Uploads.MaxBytes
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+1<<20)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.Error(problem.New(problem.PayloadTooLarge, "File too large"))
+			return
+		}
+		c.Error(problem.New(problem.BadRequest, "Missing file"))
+		return
+	}
+	if header.Size > maxBytes {
+		c.Error(problem.New(problem.PayloadTooLarge, "File too large"))
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.Error(problem.New(problem.BadRequest, "Unreadable file"))
+		return
+	}
+	defer file.Close()
+
+	// Sniff the type and hash the content in one pass
+	detected, err := mimetype.DetectReader(file)
+	if err != nil {
+		c.Error(problem.New(problem.BadRequest, "Unreadable file"))
+		return
+	}
+	mimeType, _, _ := mime.ParseMediaType(detected.String())
+	if !s.uploadTypeAllowed(mimeType) {
+		c.Error(problem.New(problem.UnsupportedMediaType, "File type not allowed"))
+		return
+	}
+	hasher := sha256.New()
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		c.Error(problem.New(problem.Internal, "Unable to read file"))
+		return
+	}
+	if _, err := io.Copy(hasher, file); err != nil {
+		c.Error(problem.New(problem.Internal, "Unable to read file"))
+		return
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+
+	// Re-uploading the same content returns the existing attachment
+	attachments := s.store(c).Attachments()
+	attachment, err := attachments.GetByHash(accountID.(uint), hash)
+	if err == nil {
+		c.JSON(http.StatusOK, gin.H{"attachment": newResponseAttachment(attachment)})
+		return
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch attachment", "hash", hash, "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to save attachment"))
+		return
+	}
+
+	attachment = models.Attachment{
+		AccountID: accountID.(uint),
+		Hash:      hash,
+		Size:      header.Size,
+		MIMEType:  mimeType,
+		Filename:  filepath.Base(header.Filename),
+	}
+	if imaging.Supported(mimeType) {
+		attachment.VariantStatus = workers.VariantsPending
+	}
+	err = attachments.Create(&attachment, s.cfg.Uploads.QuotaBytes)
+	if errors.Is(err, store.ErrQuotaExceeded) {
+		c.Error(problem.New(problem.QuotaExceeded, "Storage quota exceeded"))
+		return
+	}
+	if errors.Is(err, store.ErrConflict) {
+		// A concurrent upload of the same content won the insert
+		if attachment, err = attachments.GetByHash(accountID.(uint), hash); err == nil {
+			c.JSON(http.StatusOK, gin.H{"attachment": newResponseAttachment(attachment)})
+			return
+		}
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to create attachment", "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to save attachment"))
+		return
+	}
+
+	// The row exists before the blob so garbage collection never sees the hash unreferenced
+	err = workers.GuardBlobs(func() error {
+		stored, err := s.Blobs.Exists(c.Request.Context(), hash)
+		if err != nil || stored {
+			return err
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		return s.Blobs.Put(c.Request.Context(), hash, file, header.Size, mimeType)
+	})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to store blob", "hash", hash, "error", err)
+		if err := attachments.Delete(attachment.ID); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to remove attachment", "id", attachment.ID, "error", err)
+		}
+		c.Error(problem.New(problem.Internal, "Unable to save attachment"))
+		return
+	}
+
+	if attachment.VariantStatus == workers.VariantsPending {
+		s.Images.Enqueue(attachment.ID)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"attachment": newResponseAttachment(attachment)})
+}
+
+// AttachmentList handles GET requests for the caller's attachments and quota usage.
+func (s *Server) AttachmentList(c *gin.Context) {
+	accountID, exists := c.Get("accountID")
+	if !exists {
+		c.Error(problem.New(problem.Unauthenticated, "User is unauthenticated"))
+		return
+	}
+	attachments, err := s.store(c).Attachments().List(accountID.(uint))
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch attachments", "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to fetch attachments"))
+		return
+	}
+	used, err := s.store(c).Attachments().Usage(accountID.(uint))
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to compute storage usage", "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to fetch attachments"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"attachments": newResponseAttachments(attachments),
+		"used":        used,
+		"quota":       s.cfg.Uploads.QuotaBytes,
+	})
+}
+
+// findAttachment loads the :id attachment if the caller may read it: the
+// owner always can, anyone else only through a published post.
+func (s *Server) findAttachment(c *gin.Context) (models.Attachment, bool) {
+	var attachment models.Attachment
+	accountID, exists := c.Get("accountID")
+	if !exists {
+		c.Error(problem.New(problem.Unauthenticated, "User is unauthenticated"))
+		return attachment, false
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(problem.New(problem.BadRequest, "Invalid attachment ID"))
+		return attachment, false
+	}
+	attachment, err = s.store(c).Attachments().Get(uint(id))
+	if err != nil {
+		c.Error(problem.New(problem.NotFound, "Attachment not found"))
+		return attachment, false
+	}
+	if attachment.AccountID != accountID {
+		published, err := s.store(c).Attachments().Published(attachment.ID)
+		if err != nil || !published {
+			c.Error(problem.New(problem.NotFound, "Attachment not found"))
+			return attachment, false
+		}
+	}
+	return attachment, true
+}
+
+// AttachmentGet handles GET requests that download an attachment's content.
+// Images are only served through their generated variants, picked with
+// ?variant= and defaulting to the metadata-free original.
+func (s *Server) AttachmentGet(c *gin.Context) {
+	attachment, ok := s.findAttachment(c)
+	if !ok {
+		return
+	}
+	hash, size, mimeType := attachment.Hash, attachment.Size, attachment.MIMEType
+	name := c.Query("variant")
+	if attachment.VariantStatus == "" && name != "" {
+		c.Error(problem.New(problem.BadRequest, "Attachment has no variants"))
+		return
+	}
+	if attachment.VariantStatus != "" {
+		if name == "" {
+			name = "original"
+		}
+		switch attachment.VariantStatus {
+		case workers.VariantsPending:
+			c.Header("Retry-After", "5")
+			c.Error(problem.New(problem.Unavailable, "Image is still processing"))
+			return
+		case workers.VariantsFailed:
+			c.Error(problem.New(problem.NotFound, "Image could not be processed"))
+			return
+		}
+		variant, err := s.store(c).Attachments().Variant(attachment.ID, name)
+		if err != nil {
+			c.Error(problem.New(problem.NotFound, "Variant not found"))
+			return
+		}
+		hash, size, mimeType = variant.Hash, variant.Size, variant.MIMEType
+	}
+
+	etag := `"` + hash + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, max-age=86400")
+	if ifNoneMatchHit(c, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	blob, err := s.Blobs.Get(c.Request.Context(), hash)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to read blob", "hash", hash, "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to read attachment"))
+		return
+	}
+	defer blob.Close()
+
+	c.DataFromReader(http.StatusOK, size, mimeType, blob, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("inline", map[string]string{"filename": attachment.Filename}),
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// AttachmentDelete handles DELETE requests for an attachment no post uses.
+func (s *Server) AttachmentDelete(c *gin.Context) {
+	attachment, ok := s.findAttachment(c)
+	if !ok {
+		return
+	}
+	if attachment.AccountID != c.GetUint("accountID") {
+		c.Error(problem.New(problem.Forbidden, "Only the owner may do this"))
+		return
+	}
+	// Posts in the trash count too, so that restoring them stays possible
+	inUse, err := s.store(c).Attachments().InUse(attachment.ID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to check attachment use", "id", attachment.ID, "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to delete attachment"))
+		return
+	}
+	if inUse {
+		c.Error(problem.New(problem.Conflict, "Attachment is used by a post"))
+		return
+	}
+	if err := s.Purger.CollectAttachments([]uint{attachment.ID}); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to delete attachment", "id", attachment.ID, "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to delete attachment"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment Deleted"})
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"genesis/models"
+	"genesis/store"
+)
+
+// upload posts data as a file attachment and returns the status and attachment.
+func (a *apiServer) upload(accountID uint, data string) (int, ResponseAttachment) {
+	a.t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "notes.txt")
+	part.Write([]byte(data))
+	form.Close()
+
+	w := a.do(accountID, http.MethodPost, "/attachments/", &body, map[string]string{"Content-Type": form.FormDataContentType()})
+	var resp struct{ Attachment ResponseAttachment }
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		a.t.Fatalf("upload = %d: %s", w.Code, w.Body)
+	}
+	return w.Code, resp.Attachment
+}
+
+func TestSQLiteAttachmentDuplicate(t *testing.T) {
+	a := newAPIServer(t, "read")
+	alice := a.account("alice")
+
+	status, first := a.upload(alice, "some notes")
+	if status != http.StatusCreated {
+		t.Fatalf("first upload = %d, want 201", status)
+	}
+	status, again := a.upload(alice, "some notes")
+	if status != http.StatusOK || again.ID != first.ID {
+		t.Fatalf("repeated upload = %d with ID %d, want 200 with ID %d", status, again.ID, first.ID)
+	}
+
+	// The loser of two concurrent uploads hits the unique index
+	dup := models.Attachment{AccountID: alice, Hash: first.Hash, Size: first.Size, MIMEType: first.MIMEType}
+	if err := a.srv.Store.Attachments().Create(&dup, 1<<20); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("Create of existing content = %v, want ErrConflict", err)
+	}
+}
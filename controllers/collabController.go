@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"genesis/collab"
+	"genesis/models"
+	"genesis/problem"
+	"genesis/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// ResponseCollaborator represents a co-author of a post.
+type ResponseCollaborator struct {
+	AccountID uint      `json:"account_id"`
+	Handle    string    `json:"handle"`
+	AddedAt   time.Time `json:"added_at"`
+}
+
+// newUpgrader returns an upgrader that only accepts same-origin
+// connections, plus the listed origins, so other sites cannot ride on the
+// cookie.
+func newUpgrader(origins []string) websocket.Upgrader {
+	return websocket.Upgrader{
+		ReadBufferSize:  4096,
+		WriteBufferSize: 4096,
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				return true
+			}
+			for _, allowed := range origins {
+				if allowed == origin {
+					return true
+				}
+			}
+			return strings.TrimPrefix(strings.TrimPrefix(origin, "https://"), "http://") == r.Host
+		},
+	}
+}
+
+// findEditablePost loads the post named by :id when the caller is its
+// author or a co-author.
+func (s *Server) findEditablePost(c *gin.Context) (models.Post, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(problem.New(problem.BadRequest, "Invalid post ID"))
+		return models.Post{}, false
+	}
+	post, err := s.store(c).Posts().Get(uint(id))
+	if err != nil {
+		c.Error(problem.New(problem.NotFound, "Post not found"))
+		return post, false
+	}
+	accountID := c.GetUint("accountID")
+	if post.AccountID == accountID {
+		return post, true
+	}
+	shared, err := s.store(c).Posts().IsCollaborator(post.ID, accountID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to check collaborator", "post_id", post.ID, "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to check collaborators"))
+		return post, false
+	}
+	if !shared {
+		c.Error(problem.New(problem.Forbidden, "Not a co-author of this post"))
+		return post, false
+	}
+	return post, true
+}
+
+// PostLive handles WebSocket upgrades for live editing of a post body by
+// its author and co-authors. Edits are merged with operational transforms
+// and written back to the post in place every few seconds.
+func (s *Server) PostLive(c *gin.Context) {
+	post, ok := s.findEditablePost(c)
+	if !ok {
+		return
+	}
+	account, err := s.store(c).Accounts().Get(c.GetUint("accountID"))
+	if err != nil {
+		c.Error(problem.New(problem.NotFound, "Account not found"))
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already answered the request
+		return
+	}
+	if err := s.Collab.Serve(conn, post.ID, collab.Participant{AccountID: account.ID, Handle: account.Handle}); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to open editing session", "post_id", post.ID, "error", err)
+	}
+}
+
+// CollaboratorList handles GET requests for the co-authors of a post.
+func (s *Server) CollaboratorList(c *gin.Context) {
+	post, ok := s.findEditablePost(c)
+	if !ok {
+		return
+	}
+	collaborators, err := s.store(c).Posts().Collaborators(post.ID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch collaborators", "post_id", post.ID, "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to fetch collaborators"))
+		return
+	}
+	resps := make([]ResponseCollaborator, len(collaborators))
+	for i, collaborator := range collaborators {
+		resps[i] = ResponseCollaborator(collaborator)
+	}
+	c.JSON(http.StatusOK, gin.H{"collaborators": resps})
+}
+
+// CollaboratorAdd handles PUT requests by a post's author to add a co-author.
+func (s *Server) CollaboratorAdd(c *gin.Context) {
+	post, account, ok := s.collaboratorParams(c)
+	if !ok {
+		return
+	}
+	if account.ID == post.AccountID {
+		c.Error(problem.New(problem.Conflict, "The author is already an editor"))
+		return
+	}
+	if err := s.store(c).Posts().AddCollaborator(post.ID, account.ID); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to add collaborator", "post_id", post.ID, "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to add collaborator"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Collaborator Added"})
+}
+
+// CollaboratorRemove handles DELETE requests by a post's author to remove
+// a co-author and closes their open editing connections.
+func (s *Server) CollaboratorRemove(c *gin.Context) {
+	post, account, ok := s.collaboratorParams(c)
+	if !ok {
+		return
+	}
+	if err := s.store(c).Posts().RemoveCollaborator(post.ID, account.ID); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to remove collaborator", "post_id", post.ID, "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to remove collaborator"))
+		return
+	}
+	s.Collab.Disconnect(post.ID, account.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Collaborator Removed"})
+}
+
+// collaboratorParams loads the caller's post named by :id and the account
+// named by :handle.
+func (s *Server) collaboratorParams(c *gin.Context) (models.Post, models.Account, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(problem.New(problem.BadRequest, "Invalid post ID"))
+		return models.Post{}, models.Account{}, false
+	}
+	post, err := s.store(c).Posts().Get(uint(id))
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			slog.ErrorContext(c.Request.Context(), "Failed to fetch post", "post_id", id, "error", err)
+		}
+		c.Error(problem.New(problem.NotFound, "Post not found"))
+		return post, models.Account{}, false
+	}
+	if post.AccountID != c.GetUint("accountID") {
+		c.Error(problem.New(problem.Forbidden, "Only the owner may do this"))
+		return post, models.Account{}, false
+	}
+	account, ok := s.findHandle(c)
+	return post, account, ok
+}
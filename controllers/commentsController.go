@@ -0,0 +1,272 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"genesis/events"
+	"genesis/models"
+	"genesis/problem"
+	"genesis/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestCommentBody represents the expected JSON payload for creating a comment.
+type RequestCommentBody struct {
+	Body     string `json:"body" binding:"required,max=10000"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+// RequestCommentEdit represents the expected JSON payload for editing a comment.
+type RequestCommentEdit struct {
+	Body string `json:"body" binding:"required,max=10000"`
+}
+
+// RequestCommentLock represents the expected JSON payload for (un)locking comments.
+type RequestCommentLock struct {
+	Locked *bool `json:"locked" binding:"required"`
+}
+
+// ResponseComment represents the response structure for a comment and its replies.
+type ResponseComment struct {
+	ID        uint              `json:"id"`
+	Body      string            `json:"body"`
+	PostID    uint              `json:"postID"`
+	AccountID uint              `json:"accountID"`
+	ParentID  *uint             `json:"parentID"`
+	Replies   []ResponseComment `json:"replies"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+func newResponseComment(comment models.Comment) ResponseComment {
+	return ResponseComment{
+		ID:        comment.ID,
+		Body:      comment.Body,
+		PostID:    comment.PostID,
+		AccountID: comment.AccountID,
+		ParentID:  comment.ParentID,
+		Replies:   []ResponseComment{},
+		CreatedAt: comment.CreatedAt,
+		UpdatedAt: comment.UpdatedAt,
+	}
+}
+
+// CommentCreate handles POST requests to comment on a post or reply to a comment.
+func (s *Server) CommentCreate(c *gin.Context) {
+	accountID, exists := c.Get("accountID")
+	if !exists {
+		c.Error(problem.New(problem.Unauthenticated, "User is unauthenticated"))
+		return
+	}
+	postID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(problem.New(problem.BadRequest, "Invalid post ID"))
+		return
+	}
+	var req RequestCommentBody
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(problem.Invalid(err))
+		return
+	}
+
+	post, ok := s.visiblePost(c, uint(postID))
+	if !ok {
+		return
+	}
+	if post.CommentsLocked {
+		c.Error(problem.New(problem.CommentsLocked, "Comments are locked"))
+		return
+	}
+
+	// Replies must stay within the thread of the same post
+	if req.ParentID != nil {
+		parent, err := s.store(c).Comments().Get(*req.ParentID)
+		if err != nil || parent.PostID != post.ID {
+			c.Error(problem.New(problem.BadRequest, "Invalid parent comment"))
+			return
+		}
+	}
+
+	comment := models.Comment{
+		Body:      req.Body,
+		PostID:    post.ID,
+		AccountID: accountID.(uint),
+		ParentID:  req.ParentID,
+	}
+	if err := s.store(c).Comments().Create(&comment); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to create comment", "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to create comment"))
+		return
+	}
+
+	resp := newResponseComment(comment)
+	if post.AccountID != comment.AccountID {
+		s.Events.Send(events.TypeCommentCreated, []uint{post.AccountID}, resp)
+	}
+	c.JSON(http.StatusCreated, gin.H{"comment": resp})
+}
+
+// CommentList handles GET requests for a post's comments. Pagination
+// applies to top-level threads; each thread is returned with all its replies.
+func (s *Server) CommentList(c *gin.Context) {
+	postID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(problem.New(problem.BadRequest, "Invalid post ID"))
+		return
+	}
+	page, limit := pageParams(c)
+	if _, ok := s.visiblePost(c, uint(postID)); !ok {
+		return
+	}
+
+	roots, total, err := s.store(c).Comments().Threads(uint(postID), (page-1)*limit, limit)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch comments", "post_id", postID, "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to fetch comments"))
+		return
+	}
+	threads := make([]ResponseComment, len(roots))
+	for i, root := range roots {
+		threads[i] = buildThread(root)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"comments": threads,
+		"page":     page,
+		"limit":    limit,
+		"total":    total,
+	})
+}
+
+func buildThread(comment models.Comment) ResponseComment {
+	resp := newResponseComment(comment)
+	for _, reply := range comment.Replies {
+		resp.Replies = append(resp.Replies, buildThread(reply))
+	}
+	return resp
+}
+
+// CommentUpdate handles PUT requests to edit a comment. Only its author may edit it.
+func (s *Server) CommentUpdate(c *gin.Context) {
+	accountID, exists := c.Get("accountID")
+	if !exists {
+		c.Error(problem.New(problem.Unauthenticated, "User is unauthenticated"))
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(problem.New(problem.BadRequest, "Invalid comment ID"))
+		return
+	}
+	var req RequestCommentEdit
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(problem.Invalid(err))
+		return
+	}
+
+	comment, err := s.store(c).Comments().Get(uint(id))
+	if err != nil {
+		c.Error(problem.New(problem.NotFound, "Comment not found"))
+		return
+	}
+	if comment.AccountID != accountID {
+		c.Error(problem.New(problem.Forbidden, "Only the owner may do this"))
+		return
+	}
+	post, err := s.store(c).Posts().Get(comment.PostID)
+	if err != nil {
+		c.Error(problem.New(problem.NotFound, "Post not found"))
+		return
+	}
+	if post.CommentsLocked {
+		c.Error(problem.New(problem.CommentsLocked, "Comments are locked"))
+		return
+	}
+
+	if err := s.store(c).Comments().UpdateBody(comment.ID, req.Body); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to update comment", "comment_id", id, "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to update comment"))
+		return
+	}
+	comment.Body = req.Body
+	c.JSON(http.StatusOK, gin.H{"comment": newResponseComment(comment)})
+}
+
+// CommentDelete handles DELETE requests for a comment and its replies.
+// Both the comment author and the author of the post may delete it.
+func (s *Server) CommentDelete(c *gin.Context) {
+	accountID, exists := c.Get("accountID")
+	if !exists {
+		c.Error(problem.New(problem.Unauthenticated, "User is unauthenticated"))
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(problem.New(problem.BadRequest, "Invalid comment ID"))
+		return
+	}
+
+	comment, err := s.store(c).Comments().Get(uint(id))
+	if err != nil {
+		c.Error(problem.New(problem.NotFound, "Comment not found"))
+		return
+	}
+	if comment.AccountID != accountID {
+		post, err := s.store(c).Posts().Get(comment.PostID)
+		if err != nil || post.AccountID != accountID {
+			c.Error(problem.New(problem.Forbidden, "Only the owner may do this"))
+			return
+		}
+	}
+
+	if err := s.store(c).Comments().DeleteThread(comment.ID); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to delete comment", "comment_id", id, "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to delete comment"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Comment Deleted"})
+}
+
+// CommentLock handles PUT requests by a post's author to lock or unlock its comments.
+func (s *Server) CommentLock(c *gin.Context) {
+	accountID, exists := c.Get("accountID")
+	if !exists {
+		c.Error(problem.New(problem.Unauthenticated, "User is unauthenticated"))
+		return
+	}
+	postID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(problem.New(problem.BadRequest, "Invalid post ID"))
+		return
+	}
+	var req RequestCommentLock
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(problem.Invalid(err))
+		return
+	}
+
+	post, err := s.store(c).Posts().Get(uint(postID))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.Error(problem.New(problem.NotFound, "Post not found"))
+			return
+		}
+		c.Error(problem.New(problem.Internal, "Failed to fetch post"))
+		return
+	}
+	if post.AccountID != accountID {
+		c.Error(problem.New(problem.Forbidden, "Only the owner may do this"))
+		return
+	}
+	if err := s.store(c).Posts().LockComments(post.ID, *req.Locked); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to lock comments", "post_id", postID, "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to update post"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"locked": *req.Locked})
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestSQLiteCommentThreads(t *testing.T) {
+	a := newAPIServer(t, "read")
+	alice, bob := a.account("alice"), a.account("bob")
+	post := a.createPost(alice, `{"title": "Hello", "body": "world"}`)
+	path := postPath(post.ID) + "/comments"
+
+	comment := func(accountID uint, body string) ResponseComment {
+		var resp struct{ Comment ResponseComment }
+		a.doJSON(accountID, http.MethodPost, path, body, http.StatusCreated, &resp)
+		return resp.Comment
+	}
+	root := comment(bob, `{"body": "first"}`)
+	reply := comment(alice, fmt.Sprintf(`{"body": "reply", "parent_id": %d}`, root.ID))
+	comment(bob, fmt.Sprintf(`{"body": "nested", "parent_id": %d}`, reply.ID))
+	comment(alice, `{"body": "second"}`)
+
+	var list struct {
+		Comments []ResponseComment
+		Total    int64
+	}
+	a.doJSON(bob, http.MethodGet, path+"?limit=1", "", http.StatusOK, &list)
+	if list.Total != 2 || len(list.Comments) != 1 {
+		t.Fatalf("first page = %d threads of %d, want 1 of 2", len(list.Comments), list.Total)
+	}
+	thread := list.Comments[0]
+	if thread.ID != root.ID || len(thread.Replies) != 1 || len(thread.Replies[0].Replies) != 1 {
+		t.Fatalf("thread = %+v, want the first comment with its nested replies", thread)
+	}
+	if got := thread.Replies[0].Replies[0].Body; got != "nested" {
+		t.Fatalf("nested reply = %q, want nested", got)
+	}
+
+	// Replies must stay on the same post
+	other := a.createPost(alice, `{"title": "Other", "body": "post"}`)
+	body := fmt.Sprintf(`{"body": "misplaced", "parent_id": %d}`, root.ID)
+	a.doJSON(bob, http.MethodPost, postPath(other.ID)+"/comments", body, http.StatusBadRequest, nil)
+}
+
+func TestSQLiteCommentVisibility(t *testing.T) {
+	a := newAPIServer(t, "read")
+	alice, bob := a.account("alice"), a.account("bob")
+	draft := a.createPost(alice, `{"title": "Draft", "body": "hidden", "draft": true}`)
+	path := postPath(draft.ID) + "/comments"
+
+	a.doJSON(bob, http.MethodPost, path, `{"body": "peek"}`, http.StatusNotFound, nil)
+	a.doJSON(bob, http.MethodGet, path, "", http.StatusNotFound, nil)
+	a.doJSON(alice, http.MethodPost, path, `{"body": "note to self"}`, http.StatusCreated, nil)
+	a.doJSON(bob, http.MethodGet, "/posts/999/comments", "", http.StatusNotFound, nil)
+
+	post := a.createPost(alice, `{"title": "Gone", "body": "soon"}`)
+	a.doJSON(alice, http.MethodDelete, postPath(post.ID), "", http.StatusOK, nil)
+	a.doJSON(bob, http.MethodGet, postPath(post.ID)+"/comments", "", http.StatusNotFound, nil)
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etagFor builds a strong entity tag from a resource ID and its version.
+func etagFor(id, version uint) string {
+	return fmt.Sprintf(`"%d-%d"`, id, version)
+}
+
+// representationETag tags one representation of a versioned resource.
+// The version leads so that writes can be checked against it alone; the
+// digest covers the rest of body, which can change without a new version.
+func representationETag(id, version uint, body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`"%d-%d.%s"`, id, version, hex.EncodeToString(sum[:8]))
+}
+
+// etagMatches reports whether etag appears in a comma separated
+// If-Match / If-None-Match header value. "*" matches any current entity.
+// Weak validators are compared by their opaque tag.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchFailed reports whether the request carries an If-Match header
+// that does not match the current etag.
+func ifMatchFailed(c *gin.Context, etag string) bool {
+	header := c.GetHeader("If-Match")
+	return header != "" && !etagMatches(header, etag)
+}
+
+// ifVersionMatchFailed is ifMatchFailed for writes: a tag from any
+// representation of the current version matches, since what the
+// representations add does not change what a write overwrites.
+func ifVersionMatchFailed(c *gin.Context, id, version uint) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return false
+	}
+	current := etagFor(id, version)
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if tag, _, ok := strings.Cut(candidate, "."); ok {
+			candidate = tag + `"`
+		}
+		if candidate == "*" || candidate == current {
+			return false
+		}
+	}
+	return true
+}
+
+// ifNoneMatchHit reports whether the request carries an If-None-Match
+// header matching the current etag, i.e. the client copy is fresh.
+func ifNoneMatchHit(c *gin.Context, etag string) bool {
+	header := c.GetHeader("If-None-Match")
+	return header != "" && etagMatches(header, etag)
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"genesis/events"
+	"genesis/models"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// eventHeartbeat keeps idle streams from being closed by proxies.
+const eventHeartbeat = 20 * time.Second
+
+// EventStream handles GET requests for the caller's Server-Sent Events
+// stream. Clients that reconnect with Last-Event-ID (or ?last_event_id=
+// where headers can't be set) first receive the events they missed, as
+// far back as the replay buffer reaches.
+func (s *Server) EventStream(c *gin.Context) {
+	accountID := c.GetUint("accountID")
+	lastID := c.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("last_event_id")
+	}
+
+	missed, live, cancel := s.Events.Subscribe(accountID, lastID)
+	defer cancel()
+
+	// The stream outlives the server's write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to clear write deadline of event stream", "error", err)
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	for _, event := range missed {
+		writeEvent(c, event)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-live:
+			if !ok {
+				return false
+			}
+			writeEvent(c, event)
+		case <-heartbeat.C:
+			io.WriteString(w, ": heartbeat\n\n")
+		}
+		return true
+	})
+}
+
+func writeEvent(c *gin.Context, event events.Event) {
+	c.Render(-1, sse.Event{
+		Id:    event.ID,
+		Event: event.Type,
+		Data:  []byte(event.Data),
+	})
+}
+
+// announcePost tells the author's followers about a newly published post.
+func (s *Server) announcePost(ctx context.Context, post models.Post) {
+	followers, err := s.Store.WithContext(ctx).Follows().FollowerIDs(post.AccountID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to fetch followers", "account_id", post.AccountID, "error", err)
+		return
+	}
+	s.Events.Send(events.TypePostPublished, followers, gin.H{
+		"id":         post.ID,
+		"title":      post.Title,
+		"slug":       post.Slug,
+		"account_id": post.AccountID,
+		"created_at": post.CreatedAt,
+	})
+}
+
+// announceTransfer tells the recipient about money that reached their
+// wallet. Code that moves money calls it once the transfer has committed,
+// so a transfer that rolled back is never announced.
+func (s *Server) announceTransfer(transfer models.Transfer, recipientID uint, currency string) {
+	s.Events.Send(events.TypeTransferReceived, []uint{recipientID}, gin.H{
+		"id":          transfer.ID,
+		"from_wallet": transfer.FromAccountID,
+		"to_wallet":   transfer.ToAccountID,
+		"amount":      transfer.Amount,
+		"currency":    currency,
+		"created_at":  transfer.CreatedAt,
+	})
+}
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"genesis/feed"
+	"genesis/problem"
+	"genesis/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Feed formats selectable with the :format path parameter.
+const (
+	feedRSS  = "rss"
+	feedAtom = "atom"
+)
+
+// feedLimit reads ?limit=, falling back to the configured feed size.
+func (s *Server) feedLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = s.cfg.Feeds.Items
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	return limit
+}
+
+// siteURL is the absolute base for links in feeds. The configured site
+// URL wins over the request's own host so that feeds behind a proxy link
+// to the public name.
+func (s *Server) siteURL(c *gin.Context) string {
+	if base := s.cfg.Server.SiteURL; base != "" {
+		return strings.TrimSuffix(base, "/")
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// SiteFeed handles GET requests for the feed of all published posts.
+func (s *Server) SiteFeed(c *gin.Context) {
+	s.serveFeed(c, 0, "Latest posts", "Recently published posts", "/")
+}
+
+// AuthorFeed handles GET requests for the feed of one author's published posts.
+func (s *Server) AuthorFeed(c *gin.Context) {
+	author, ok := s.findHandle(c)
+	if !ok {
+		return
+	}
+	s.serveFeed(c, author.ID,
+		"Posts by "+author.Handle, "Recently published posts by "+author.Handle, "/authors/"+author.Handle)
+}
+
+// serveFeed renders the newest published posts of accountID, or of
+// everyone when it is 0, in the format named by the path, answering
+// conditional requests with 304.
+func (s *Server) serveFeed(c *gin.Context, accountID uint, title, description, page string) {
+	format := c.Param("format")
+	if format != feedRSS && format != feedAtom {
+		c.Error(problem.New(problem.NotFound, "Unknown feed format"))
+		return
+	}
+	limit := s.feedLimit(c)
+
+	posts, err := s.store(c).Posts().Recent(accountID, limit)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch feed posts", "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to fetch feed"))
+		return
+	}
+
+	// The validators cover every post in the feed, so edits, deletions
+	// and new posts all change them.
+	var lastModified time.Time
+	digest := sha256.New()
+	fmt.Fprintf(digest, "%s:%d", format, limit)
+	for _, post := range posts {
+		fmt.Fprintf(digest, ":%d-%d", post.ID, post.Version)
+		if post.UpdatedAt.After(lastModified) {
+			lastModified = post.UpdatedAt
+		}
+	}
+	etag := `"` + hex.EncodeToString(digest.Sum(nil))[:32] + `"`
+	c.Header("ETag", etag)
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if feedNotModified(c, etag, lastModified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	ids := make([]uint, len(posts))
+	for i, post := range posts {
+		ids[i] = post.AccountID
+	}
+	handles, err := s.store(c).Accounts().Handles(ids)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch feed authors", "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to fetch feed"))
+		return
+	}
+
+	base := s.siteURL(c)
+	f := feed.Feed{
+		ID:          base + c.Request.URL.Path,
+		Title:       title,
+		Description: description,
+		Link:        base + page,
+		Self:        base + c.Request.URL.RequestURI(),
+		Updated:     lastModified,
+	}
+	for _, post := range posts {
+		rendered := postBody(post, bodyHTML)
+		f.Items = append(f.Items, feed.Item{
+			ID:        fmt.Sprintf("%s/posts/%d", base, post.ID),
+			Title:     post.Title,
+			Link:      base + permalinkPath(handles[post.AccountID], post.Slug),
+			Author:    handles[post.AccountID],
+			Summary:   render.Excerpt(rendered, excerptLength),
+			Content:   rendered,
+			Published: post.CreatedAt,
+			Updated:   post.UpdatedAt,
+		})
+	}
+
+	encode, contentType := feed.RSS, feed.RSSContentType
+	if format == feedAtom {
+		encode, contentType = feed.Atom, feed.AtomContentType
+	}
+	body, err := encode(f)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to render feed", "format", format, "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to render feed"))
+		return
+	}
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// feedNotModified applies If-None-Match, or If-Modified-Since when no
+// entity tag was sent.
+func feedNotModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	if c.GetHeader("If-None-Match") != "" {
+		return ifNoneMatchHit(c, etag)
+	}
+	since, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+	return err == nil && !lastModified.IsZero() && !lastModified.Truncate(time.Second).After(since)
+}
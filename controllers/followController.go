@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"genesis/models"
+	"genesis/problem"
+	"genesis/store"
+	"genesis/timeline"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResponseFollow represents an account in a follower or following list.
+type ResponseFollow struct {
+	ID         uint      `json:"id"`
+	Handle     string    `json:"handle"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+// findHandle loads the account behind the :handle path parameter.
+func (s *Server) findHandle(c *gin.Context) (models.Account, bool) {
+	account, err := s.store(c).Accounts().GetByHandle(strings.ToLower(c.Param("handle")))
+	if errors.Is(err, store.ErrNotFound) {
+		c.Error(problem.New(problem.NotFound, "Account not found"))
+		return account, false
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch account", "handle", c.Param("handle"), "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to fetch account"))
+		return account, false
+	}
+	return account, true
+}
+
+// notifyTimeline reports a post change to the timeline strategy. Failures
+// only delay the post reaching timelines, so they are logged.
+func (s *Server) notifyTimeline(post models.Post) {
+	if err := s.Timeline.PostChanged(post); err != nil {
+		slog.Error("Failed to fan out post", "post_id", post.ID, "error", err)
+	}
+}
+
+// AccountFollow handles POST requests to follow the account named by handle.
+// Following an account twice is a no-op.
+func (s *Server) AccountFollow(c *gin.Context) {
+	accountID := c.GetUint("accountID")
+	followee, ok := s.findHandle(c)
+	if !ok {
+		return
+	}
+	if followee.ID == accountID {
+		c.Error(problem.New(problem.BadRequest, "Cannot follow yourself"))
+		return
+	}
+
+	created, err := s.store(c).Follows().Follow(accountID, followee.ID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to follow", "followee_id", followee.ID, "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to follow account"))
+		return
+	}
+	if created {
+		if err := s.Timeline.Followed(accountID, followee.ID); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to backfill timeline", "error", err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Following " + followee.Handle})
+}
+
+// AccountUnfollow handles DELETE requests to stop following an account.
+func (s *Server) AccountUnfollow(c *gin.Context) {
+	accountID := c.GetUint("accountID")
+	followee, ok := s.findHandle(c)
+	if !ok {
+		return
+	}
+
+	removed, err := s.store(c).Follows().Unfollow(accountID, followee.ID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to unfollow", "followee_id", followee.ID, "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to unfollow account"))
+		return
+	}
+	if removed {
+		if err := s.Timeline.Unfollowed(accountID, followee.ID); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to prune timeline", "error", err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Unfollowed " + followee.Handle})
+}
+
+// AccountFollowers handles GET requests to list who follows an account.
+func (s *Server) AccountFollowers(c *gin.Context) {
+	s.listFollows(c, store.FollowStore.Followers)
+}
+
+// AccountFollowing handles GET requests to list whom an account follows.
+func (s *Server) AccountFollowing(c *gin.Context) {
+	s.listFollows(c, store.FollowStore.Following)
+}
+
+// listFollows pages through one side of the follows of the account named
+// in the path, newest first.
+func (s *Server) listFollows(c *gin.Context, list func(store.FollowStore, uint, int, int) ([]store.FollowEntry, int64, error)) {
+	account, ok := s.findHandle(c)
+	if !ok {
+		return
+	}
+	page, limit := pageParams(c)
+
+	entries, total, err := list(s.store(c).Follows(), account.ID, (page-1)*limit, limit)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch follows", "of_account_id", account.ID, "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to fetch follows"))
+		return
+	}
+	resps := make([]ResponseFollow, len(entries))
+	for i, entry := range entries {
+		resps[i] = ResponseFollow(entry)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"accounts": resps,
+		"page":     page,
+		"limit":    limit,
+		"total":    total,
+	})
+}
+
+// TimelineHome handles GET requests for the caller's home timeline: the
+// published posts of followed accounts, newest first. Pages are linked by
+// the opaque next_cursor, which is empty on the last page.
+func (s *Server) TimelineHome(c *gin.Context) {
+	accountID := c.GetUint("accountID")
+
+	var before *timeline.Cursor
+	if token := c.Query("cursor"); token != "" {
+		cursor, err := timeline.DecodeCursor(token)
+		if err != nil {
+			c.Error(problem.New(problem.BadRequest, "Invalid cursor"))
+			return
+		}
+		before = cursor
+	}
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	entries, err := s.Timeline.Home(accountID, before, limit)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch timeline", "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to fetch timeline"))
+		return
+	}
+
+	ids := make([]uint, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.PostID
+	}
+	posts, err := s.store(c).Posts().List(store.PostFilter{IDs: ids, PublishedOnly: true})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch timeline posts", "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to fetch timeline"))
+		return
+	}
+
+	// Keep the strategy's order; entries whose post is gone are skipped
+	byID := make(map[uint]models.Post, len(posts))
+	for _, post := range posts {
+		byID[post.ID] = post
+	}
+	variant := bodyVariant(c)
+	resps := []ResponsePost{}
+	for _, id := range ids {
+		if post, ok := byID[id]; ok {
+			resps = append(resps, newResponsePost(post, variant))
+		}
+	}
+	if err := s.attachReactions(c.Request.Context(), resps, accountID); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch reactions", "error", err)
+	}
+
+	next := ""
+	if len(entries) == limit {
+		next = entries[len(entries)-1].Encode()
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"posts":       resps,
+		"next_cursor": next,
+	})
+}
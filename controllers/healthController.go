@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"genesis/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Health statuses, for the whole instance and for each check.
+const (
+	healthOK       = "ok"
+	healthDegraded = "degraded"
+	healthFailed   = "failed"
+)
+
+// ResponseCheck represents the outcome of one readiness check. Why a
+// check failed is only logged, since the endpoint is public.
+type ResponseCheck struct {
+	Status     string `json:"status"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// readinessCheck inspects one dependency.
+type readinessCheck func(ctx context.Context) error
+
+// Healthz handles GET requests from liveness probes. It answers as long
+// as the process can serve requests, whatever the state of its
+// dependencies, so a database outage does not get the instance restarted.
+func (s *Server) Healthz(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"status": healthOK})
+}
+
+// Readyz handles GET requests from readiness probes. Every dependency is
+// checked concurrently within the configured timeout, and any failure
+// answers 503 so traffic is routed to other instances.
+func (s *Server) Readyz(c *gin.Context) {
+	checks := map[string]readinessCheck{
+		"database":   s.checkDatabase,
+		"migrations": s.checkMigrations,
+		"events":     s.checkEvents,
+		"blobs":      s.checkBlobs,
+	}
+
+	results := make(map[string]ResponseCheck, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := s.runCheck(c.Request.Context(), name, check)
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	status, code := healthOK, http.StatusOK
+	for _, result := range results {
+		if result.Status != healthOK {
+			status, code = healthDegraded, http.StatusServiceUnavailable
+		}
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(code, gin.H{"status": status, "checks": results})
+}
+
+// runCheck runs the check called name with a deadline and logs why it
+// failed. A check that ignores its context is abandoned once the
+// deadline passes.
+func (s *Server) runCheck(ctx context.Context, name string, check readinessCheck) ResponseCheck {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Health.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", s.cfg.Health.Timeout)
+	}
+	resp := ResponseCheck{Status: healthOK, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		resp.Status = healthFailed
+		slog.WarnContext(ctx, "Readiness check failed", "check", name, "error", err)
+	}
+	return resp
+}
+
+func (s *Server) checkDatabase(ctx context.Context) error {
+	return s.Store.Ping(ctx)
+}
+
+// checkMigrations fails while the database is behind the schema this
+// build expects. A newer schema is accepted, since instances of the
+// previous release keep running while a new one rolls out.
+func (s *Server) checkMigrations(ctx context.Context) error {
+	version, err := s.Store.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if version < database.SchemaVersion {
+		return fmt.Errorf("database schema is at version %d, run the migration to reach %d", version, database.SchemaVersion)
+	}
+	return nil
+}
+
+func (s *Server) checkEvents(ctx context.Context) error {
+	if err := s.Events.Ping(ctx); err != nil {
+		return fmt.Errorf("%s broker: %w", s.cfg.Events.Broker, err)
+	}
+	return nil
+}
+
+func (s *Server) checkBlobs(ctx context.Context) error {
+	if err := s.Blobs.Ping(ctx); err != nil {
+		return fmt.Errorf("%s blob store: %w", s.cfg.Storage.Backend, err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSQLitePermalinkRedirect(t *testing.T) {
+	a := newAPIServer(t, "read")
+	alice := a.account("alice")
+	post := a.createPost(alice, `{"title": "First Title", "body": "text"}`)
+	if post.Slug != "first-title" {
+		t.Fatalf("slug = %q, want first-title", post.Slug)
+	}
+	a.doJSON(alice, http.MethodGet, "/authors/alice/posts/first-title", "", http.StatusOK, nil)
+
+	a.doJSON(alice, http.MethodPut, postPath(post.ID), `{"title": "Second Title", "body": "text"}`, http.StatusOK, nil)
+	w := a.do(alice, http.MethodGet, "/authors/alice/posts/first-title", nil, nil)
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/authors/alice/posts/second-title" {
+		t.Fatalf("old slug = %d to %q, want 301 to the new permalink", w.Code, w.Header().Get("Location"))
+	}
+	a.doJSON(alice, http.MethodGet, "/authors/alice/posts/second-title", "", http.StatusOK, nil)
+
+	// Redirected slugs stay reserved, so old links never change target
+	other := a.createPost(alice, `{"title": "First Title", "body": "again"}`)
+	if other.Slug == "first-title" {
+		t.Fatal("a new post took over a redirected slug")
+	}
+	if w = a.do(alice, http.MethodGet, "/authors/alice/posts/first-title", nil, nil); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("old slug after a new post = %d, want 301", w.Code)
+	}
+}
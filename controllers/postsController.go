@@ -1,56 +1,166 @@
 package controllers
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
-	"genesis/initializers"
+	"genesis/metrics"
 	"genesis/models"
+	"genesis/problem"
+	"genesis/render"
+	"genesis/store"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 // RequestPost represents the expected JSON payload for creating a post.
 type RequestPostBody struct {
 	Title string `json:"title" binding:"required,max=255"`
 	Body  string `json:"body"  binding:"required,max=65535"`
+	// Tags are normalized to slugs. Omitting them on update keeps the
+	// current tags; an empty list clears them.
+	Tags []string `json:"tags" binding:"max=20,dive,max=64"`
+	// Draft posts are visible only to their author, which is what lets
+	// search skip them. Omitting it on update keeps the current state.
+	Draft  *bool  `json:"draft"`
+	Format string `json:"format" binding:"omitempty,oneof=plain markdown"`
+	// Attachments are IDs of the author's uploads, with the same
+	// omit-to-keep semantics as Tags.
+	Attachments []uint `json:"attachments" binding:"max=50"`
 }
 
-// ResponsePost represents the response structure for a post.
+// ResponsePost represents the response structure for a post. Body holds
+// the variant selected with the ?body= query parameter.
 type ResponsePost struct {
-	ID        uint      `json:"id"`
-	Title     string    `json:"title"`
-	Body      string    `json:"body"`
-	AccountID uint      `json:"accountID"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          uint                 `json:"id"`
+	Title       string               `json:"title"`
+	Slug        string               `json:"slug"`
+	Body        string               `json:"body"`
+	Format      string               `json:"format"`
+	AccountID   uint                 `json:"accountID"`
+	Version     uint                 `json:"version"`
+	Draft       bool                 `json:"draft"`
+	Reactions   map[string]int64     `json:"reactions"`
+	MyReactions []string             `json:"my_reactions"`
+	Tags        []string             `json:"tags"`
+	Attachments []ResponseAttachment `json:"attachments"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+}
+
+// Body variants selectable with the ?body= query parameter.
+const (
+	bodyRaw     = "raw"
+	bodyHTML    = "html"
+	bodyExcerpt = "excerpt"
+
+	excerptLength = 280
+)
+
+// bodyVariant reads the requested body variant, defaulting to raw.
+func bodyVariant(c *gin.Context) string {
+	switch v := c.Query("body"); v {
+	case bodyHTML, bodyExcerpt:
+		return v
+	}
+	return bodyRaw
+}
+
+// renderPost refreshes the cached HTML rendering of a post's body.
+func renderPost(post *models.Post) error {
+	rendered, err := render.HTML(post.Format, post.Body)
+	if err != nil {
+		return err
+	}
+	post.BodyHTML = rendered
+	return nil
+}
+
+// postBody returns the requested variant of a post's body. Rows written
+// before bodies were rendered fall back to rendering on the fly.
+func postBody(post models.Post, variant string) string {
+	if variant == bodyRaw {
+		return post.Body
+	}
+	rendered := post.BodyHTML
+	if rendered == "" && post.Body != "" {
+		if err := renderPost(&post); err != nil {
+			slog.Error("Failed to render post", "post_id", post.ID, "error", err)
+		}
+		rendered = post.BodyHTML
+	}
+	if variant == bodyExcerpt {
+		return render.Excerpt(rendered, excerptLength)
+	}
+	return rendered
+}
+
+// newResponsePost maps a stored post onto its response representation.
+func newResponsePost(post models.Post, variant string) ResponsePost {
+	return ResponsePost{
+		ID:          post.ID,
+		Title:       post.Title,
+		Slug:        post.Slug,
+		Body:        postBody(post, variant),
+		Format:      post.Format,
+		AccountID:   post.AccountID,
+		Version:     post.Version,
+		Draft:       post.Draft,
+		Reactions:   map[string]int64{},
+		MyReactions: []string{},
+		Tags:        tagSlugs(post.Tags),
+		Attachments: newResponseAttachments(post.Attachments),
+		CreatedAt:   post.CreatedAt,
+		UpdatedAt:   post.UpdatedAt,
+	}
+}
+
+// errUnrenderable signals that a post body could not be rendered.
+var errUnrenderable = errors.New("unable to render post body")
+
+// createPost stores a new post with its tags and attachments, then brings
+// the search index and timelines up to date. A zero Format means plain.
+func (s *Server) createPost(ctx context.Context, post *models.Post, tags []string, attachmentIDs []uint) error {
+	if post.Format == "" {
+		post.Format = render.FormatPlain
+	}
+	if err := renderPost(post); err != nil {
+		return fmt.Errorf("%w: %v", errUnrenderable, err)
+	}
+
+	if err := s.Store.WithContext(ctx).Posts().Create(post, tags, attachmentIDs); err != nil {
+		return err
+	}
+
+	s.indexPost(*post)
+	s.notifyTimeline(*post)
+	return nil
 }
 
 // PostsCreate handles POST requests to create a new post.
-func PostsCreate(c *gin.Context) {
+func (s *Server) PostsCreate(c *gin.Context) {
 	accountID, exists := c.Get("accountID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User is unauthenticated"})
+		c.Error(problem.New(problem.Unauthenticated, "User is unauthenticated"))
 		return
 	}
 
 	// Parse and validate request body
 	var req RequestPostBody
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request body",
-		})
+		c.Error(problem.Invalid(err))
 		return
 	}
-	fmt.Printf("accountID: %v, Type: %T\n", accountID, accountID)
-	var account models.Account
-	if err := initializers.DB.Select("id").First(&account, accountID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	account, err := s.store(c).Accounts().Get(accountID.(uint))
+	if err != nil {
+		c.Error(problem.New(problem.NotFound, "Account not found"))
 		return
 	}
 
@@ -58,108 +168,234 @@ func PostsCreate(c *gin.Context) {
 	post := models.Post{
 		Title:     req.Title,
 		Body:      req.Body,
+		Format:    req.Format,
 		AccountID: account.ID,
+		Draft:     req.Draft != nil && *req.Draft,
 	}
-
-	if err := initializers.DB.Create(&post).Error; err != nil {
-		log.Printf("Failed to create post: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to create post",
-		})
+	err = s.createPost(c.Request.Context(), &post, req.Tags, req.Attachments)
+	if errors.Is(err, errUnrenderable) {
+		c.Error(problem.New(problem.BadRequest, "Unable to render post body"))
+		return
+	}
+	if errors.Is(err, store.ErrInvalidAttachment) {
+		c.Error(problem.New(problem.BadRequest, "Invalid attachment"))
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to create post", "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to create post"))
 		return
 	}
 
-	// Prepare response
-	resp := ResponsePost{
-		ID:        post.ID,
-		Title:     post.Title,
-		Body:      post.Body,
-		CreatedAt: post.CreatedAt,
-		UpdatedAt: post.UpdatedAt,
+	metrics.PostsCreated.WithLabelValues("api").Inc()
+	if !post.Draft {
+		s.announcePost(c.Request.Context(), post)
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"post": resp,
-	})
+	// Prepare response
+	body, etag, err := encodePost(post, newResponsePost(post, bodyVariant(c)))
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to encode post", "post_id", post.ID, "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to encode post"))
+		return
+	}
+	writePostBody(c, http.StatusCreated, body, etag)
 }
 
 // PostGet handles GET requests to retrieve a post by ID.
-func PostGet(c *gin.Context) {
+func (s *Server) PostGet(c *gin.Context) {
 	// Get and validate post ID
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid post ID",
-		})
+		c.Error(problem.New(problem.BadRequest, "Invalid post ID"))
 		return
 	}
 
 	// Fetch post
-	var post models.Post
-	if err := initializers.DB.Select("id, title, body, created_at, updated_at").
-		First(&post, id).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "Post not found",
-			})
+	post, err := s.fetchVisiblePost(c, func(posts store.PostStore) (models.Post, error) {
+		return posts.Get(uint(id))
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.Error(problem.New(problem.NotFound, "Post not found"))
 			return
 		}
-		log.Printf("Failed to fetch post %d: %v", id, err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to fetch post",
-		})
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch post", "post_id", id, "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to fetch post"))
 		return
 	}
+	s.writePost(c, post)
+}
 
-	// Prepare response
-	resp := ResponsePost{
-		ID:        post.ID,
-		Title:     post.Title,
-		Body:      post.Body,
-		CreatedAt: post.CreatedAt,
-		UpdatedAt: post.UpdatedAt,
+// PostPermalink handles GET requests for a post by author handle and slug.
+// Slugs a post used before a title change answer with a permanent redirect
+// to the current permalink.
+func (s *Server) PostPermalink(c *gin.Context) {
+	handle := strings.ToLower(c.Param("handle"))
+	slug := c.Param("slug")
+
+	author, err := s.store(c).Accounts().GetByHandle(handle)
+	if err != nil {
+		c.Error(problem.New(problem.NotFound, "Author not found"))
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"post": resp,
+	post, err := s.fetchVisiblePost(c, func(posts store.PostStore) (models.Post, error) {
+		return posts.GetBySlug(author.ID, slug)
 	})
+	if err == nil {
+		s.writePost(c, post)
+		return
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch post", "handle", handle, "slug", slug, "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to fetch post"))
+		return
+	}
+
+	// Fall back to slugs the post used to have
+	if target, err := s.store(c).Posts().RedirectTarget(author.ID, slug); err == nil {
+		if post, err := s.fetchVisiblePost(c, func(posts store.PostStore) (models.Post, error) {
+			return posts.Get(target)
+		}); err == nil {
+			c.Redirect(http.StatusMovedPermanently, permalinkPath(author.Handle, post.Slug))
+			return
+		}
+	}
+	c.Error(problem.New(problem.NotFound, "Post not found"))
+}
+
+func permalinkPath(handle, slug string) string {
+	return "/authors/" + handle + "/posts/" + slug
 }
 
-func PostList(c *gin.Context) {
+// fetchVisiblePost loads a single post with fetch, treating other
+// authors' drafts and posts of deleted accounts as missing.
+func (s *Server) fetchVisiblePost(c *gin.Context, fetch func(store.PostStore) (models.Post, error)) (models.Post, error) {
+	post, err := fetch(s.store(c).Posts())
+	if err != nil {
+		return post, err
+	}
+	if post.Draft && post.AccountID != c.GetUint("accountID") {
+		return post, store.ErrNotFound
+	}
+	_, err = s.store(c).Accounts().Get(post.AccountID)
+	return post, err
+}
+
+// visiblePost loads a post the caller may see by ID, answering 404 for
+// missing, trashed and other authors' draft posts.
+func (s *Server) visiblePost(c *gin.Context, id uint) (models.Post, bool) {
+	post, err := s.fetchVisiblePost(c, func(posts store.PostStore) (models.Post, error) {
+		return posts.Get(id)
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		c.Error(problem.New(problem.NotFound, "Post not found"))
+		return post, false
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch post", "post_id", id, "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to fetch post"))
+		return post, false
+	}
+	return post, true
+}
+
+// writePost responds with a single post, or 304 when the client already
+// holds this representation of it.
+func (s *Server) writePost(c *gin.Context, post models.Post) {
+	s.respondPost(c, http.StatusOK, post, c.GetUint("accountID"))
+}
+
+// respondPost writes a post with the reactions of accountID. Only GET
+// requests are answered with 304.
+func (s *Server) respondPost(c *gin.Context, status int, post models.Post, accountID uint) {
+	resps := []ResponsePost{newResponsePost(post, bodyVariant(c))}
+	if err := s.attachReactions(c.Request.Context(), resps, accountID); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch reactions", "post_id", post.ID, "error", err)
+	}
+	body, etag, err := encodePost(post, resps[0])
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to encode post", "post_id", post.ID, "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to encode post"))
+		return
+	}
+	if c.Request.Method == http.MethodGet && ifNoneMatchHit(c, etag) {
+		c.Header("ETag", etag)
+		c.Header("Vary", "Cookie")
+		c.Status(http.StatusNotModified)
+		return
+	}
+	writePostBody(c, status, body, etag)
+}
+
+// encodePost serializes a post response and tags it. The tag covers the
+// whole body, so reactions, tags and the ?body= variant each get their own.
+func encodePost(post models.Post, resp ResponsePost) ([]byte, string, error) {
+	body, err := json.Marshal(gin.H{"post": resp})
+	if err != nil {
+		return nil, "", err
+	}
+	return body, representationETag(post.ID, post.Version, body), nil
+}
+
+// writePostBody sends an encoded post. The caller's own reactions are
+// part of it, so caches must key it by the session cookie too.
+func writePostBody(c *gin.Context, status int, body []byte, etag string) {
+	c.Header("ETag", etag)
+	c.Header("Vary", "Cookie")
+	c.Data(status, "application/json; charset=utf-8", body)
+}
+
+func (s *Server) PostList(c *gin.Context) {
 	// Get auth user ID
 	accountID, exists := c.Get("accountID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User is unauthenticated"})
+		c.Error(problem.New(problem.Unauthenticated, "User is unauthenticated"))
 		return
 	}
 	// removed the account check because it's already done in the auth middleware
 	// var account models.Account
-	// if err := initializers.DB.Select("id").First(&account, accountID).Error; err != nil {
+	// if err := s.db(c).Select("id").First(&account, accountID).Error; err != nil {
 	// 	c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 	// 	return
 	// }
 
-	// Get Posts
-	var posts []models.Post
+	// ?author=handle lists another account's published posts instead
+	authorID := accountID.(uint)
+	if handle := c.Query("author"); handle != "" {
+		author, err := s.store(c).Accounts().GetByHandle(strings.ToLower(handle))
+		if err != nil {
+			c.Error(problem.New(problem.NotFound, "Author not found"))
+			return
+		}
+		authorID = author.ID
+	}
 
-	if err := initializers.DB.Where("account_id = ?", accountID).Find(&posts).Error; err != nil {
-		log.Printf("Failed to fetch posts %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to fetch post",
-		})
+	// Get Posts, optionally narrowed to ?tags=a,b with ?match=any|all
+	filter := store.PostFilter{
+		AccountID:     authorID,
+		PublishedOnly: authorID != accountID,
+		MatchAll:      c.Query("match") == "all",
+	}
+	if raw := c.Query("tags"); raw != "" {
+		filter.Tags = strings.Split(raw, ",")
+	}
+	posts, err := s.store(c).Posts().List(filter)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch posts", "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to fetch post"))
+		return
 	}
 
+	variant := bodyVariant(c)
 	resps := make([]ResponsePost, len(posts))
 	for i, post := range posts {
-		resps[i] = ResponsePost{
-			ID:        post.ID,
-			Title:     post.Title,
-			Body:      post.Body,
-			AccountID: post.AccountID,
-			CreatedAt: post.CreatedAt,
-			UpdatedAt: post.UpdatedAt,
-		}
+		resps[i] = newResponsePost(post, variant)
+	}
+	if err := s.attachReactions(c.Request.Context(), resps, accountID.(uint)); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch reactions", "error", err)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -167,90 +403,133 @@ func PostList(c *gin.Context) {
 	})
 }
 
-func PostUpdate(c *gin.Context) {
+func (s *Server) PostUpdate(c *gin.Context) {
 	//Get post Id
 	idRaw := c.Param("id")
 	id, err := strconv.ParseUint(idRaw, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Bad request param"})
+		c.Error(problem.New(problem.BadRequest, "Invalid post ID"))
 		return
 	}
 	var req RequestPostBody
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Request Body"})
+		c.Error(problem.Invalid(err))
+		return
 	}
 	accountID, ok := c.Get("accountID")
 	if !ok {
-		c.JSON(http.StatusNotFound, gin.H{"error": "No acccount associated with jwt"})
+		c.Error(problem.New(problem.Unauthenticated, "User is unauthenticated"))
+		return
+	}
+	post, err := s.store(c).Posts().Get(uint(id))
+	if errors.Is(err, store.ErrNotFound) {
+		c.Error(problem.New(problem.NotFound, "Post not found"))
 		return
 	}
-	var post models.Post
-	if err := initializers.DB.First(&post, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch post", "post_id", id, "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to fetch post"))
 		return
 	}
-	fmt.Println("Account Id is: ", post.Title)
 	if post.AccountID != accountID {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized Action"})
+		c.Error(problem.New(problem.Forbidden, "Only the owner may do this"))
+		return
+	}
+	if ifVersionMatchFailed(c, post.ID, post.Version) {
+		c.Error(problem.New(problem.PreconditionFailed, "Post has been modified"))
 		return
 	}
 
-	post = models.Post{
-		Title:     req.Title,
-		Body:      req.Body,
-		AccountID: post.AccountID,
+	// Keep the current format unless the request names one
+	updated := post
+	updated.Title = req.Title
+	updated.Body = req.Body
+	if req.Draft != nil {
+		updated.Draft = *req.Draft
+	}
+	if req.Format != "" {
+		updated.Format = req.Format
+	}
+	if err := renderPost(&updated); err != nil {
+		c.Error(problem.New(problem.BadRequest, "Unable to render post body"))
+		return
+	}
+
+	published := post.Draft && !updated.Draft
+
+	// Update in place, guarded by the version we just read so that a
+	// concurrent writer between the read and this write is detected.
+	err = s.store(c).Posts().Update(post, &updated, req.Tags, req.Attachments)
+	if errors.Is(err, store.ErrModified) {
+		c.Error(problem.New(problem.PreconditionFailed, "Post has been modified"))
+		return
+	}
+	if errors.Is(err, store.ErrInvalidAttachment) {
+		c.Error(problem.New(problem.BadRequest, "Invalid attachment"))
+		return
 	}
-	// Or post.title = newTitle -> db.save(&post)
-	if err := initializers.DB.Create(&post).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to update post"})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to update post", "post_id", id, "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to update post"))
+		return
 	}
-	resp := ResponsePost{
-		ID:        post.ID,
-		Title:     post.Title,
-		Body:      post.Body,
-		CreatedAt: post.CreatedAt,
-		UpdatedAt: post.UpdatedAt,
+	post = updated
+	s.indexPost(post)
+	s.notifyTimeline(post)
+	if published {
+		s.announcePost(c.Request.Context(), post)
 	}
-	c.JSON(http.StatusOK, gin.H{"post": resp})
 
+	s.respondPost(c, http.StatusOK, post, post.AccountID)
 }
 
-func PostDelete(c *gin.Context) {
+func (s *Server) PostDelete(c *gin.Context) {
 	// Get post ID param
 	idRaw := c.Param("id")
 	id, err := strconv.ParseUint(idRaw, 10, 64)
 	if err != nil {
-		log.Printf("Invalid post ID: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "invalid post ID",
-		})
+		slog.InfoContext(c.Request.Context(), "Invalid post ID", "error", err)
+		c.Error(problem.New(problem.BadRequest, "Invalid post ID"))
 		return
 	}
 	accountID, exists := c.Get("accountID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User is unauthenticated"})
+		c.Error(problem.New(problem.Unauthenticated, "User is unauthenticated"))
 		return
 	}
 
 	// Fetch post by ID in DB
-	var post models.Post
-
-	if err := initializers.DB.First(&post, id).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Post not found",
-		})
+	post, err := s.store(c).Posts().Get(uint(id))
+	if errors.Is(err, store.ErrNotFound) {
+		c.Error(problem.New(problem.NotFound, "Post not found"))
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch post", "post_id", id, "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to fetch post"))
 		return
 	}
 	if post.AccountID != accountID {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized Action"})
+		c.Error(problem.New(problem.Forbidden, "Only the owner may do this"))
+		return
+	}
+	if ifVersionMatchFailed(c, post.ID, post.Version) {
+		c.Error(problem.New(problem.PreconditionFailed, "Post has been modified"))
 		return
 	}
-	if err := initializers.DB.Delete(&post).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Unable to Delete post",
-		})
+	err = s.store(c).Posts().Delete(post)
+	if errors.Is(err, store.ErrModified) {
+		c.Error(problem.New(problem.PreconditionFailed, "Post has been modified"))
 		return
 	}
+	if err != nil {
+		c.Error(problem.New(problem.Internal, "Unable to delete post"))
+		return
+	}
+	s.unindexPost(post.ID)
+	if err := s.Timeline.PostRemoved(post.ID); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to remove post from timelines", "post_id", post.ID, "error", err)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Post Deleted",
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"genesis/config"
+	"genesis/middleware"
+	"genesis/models"
+	"genesis/search"
+	"genesis/store"
+	"genesis/timeline"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// fakeStore serves posts from a map. Methods the handlers under test do
+// not call are left to the embedded nil interfaces and panic if reached.
+type fakeStore struct {
+	store.Store
+	posts map[uint]models.Post
+}
+
+func (f *fakeStore) WithContext(context.Context) store.Store { return f }
+func (f *fakeStore) Posts() store.PostStore                  { return fakePosts{posts: f.posts} }
+func (f *fakeStore) Reactions() store.ReactionStore          { return fakeReactions{} }
+func (f *fakeStore) Accounts() store.AccountStore            { return fakeAccounts{} }
+
+type fakeAccounts struct{ store.AccountStore }
+
+func (fakeAccounts) Get(id uint) (models.Account, error) {
+	return models.Account{Model: gorm.Model{ID: id}}, nil
+}
+
+type fakePosts struct {
+	store.PostStore
+	posts map[uint]models.Post
+}
+
+func (f fakePosts) Get(id uint) (models.Post, error) {
+	post, ok := f.posts[id]
+	if !ok {
+		return post, store.ErrNotFound
+	}
+	return post, nil
+}
+
+func (f fakePosts) Update(current models.Post, updated *models.Post, _ []string, _ []uint) error {
+	if f.posts[current.ID].Version != current.Version {
+		return store.ErrModified
+	}
+	updated.Version = current.Version + 1
+	f.posts[current.ID] = *updated
+	return nil
+}
+
+type fakeReactions struct{ store.ReactionStore }
+
+func (fakeReactions) Counts([]uint) ([]models.ReactionCount, error) { return nil, nil }
+func (fakeReactions) Mine([]uint, uint) ([]models.Reaction, error)  { return nil, nil }
+
+type fakeTimeline struct{ timeline.Strategy }
+
+func (fakeTimeline) PostChanged(models.Post) error { return nil }
+
+// newTestRouter serves the post handlers over posts, authenticated as accountID.
+func newTestRouter(posts map[uint]models.Post, accountID uint) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	srv := NewServer(config.Config{}, Deps{
+		Store:    &fakeStore{posts: posts},
+		Search:   search.NewMemoryIndex(),
+		Timeline: fakeTimeline{},
+	})
+	router := gin.New()
+	router.Use(middleware.Errors(), func(c *gin.Context) { c.Set("accountID", accountID) })
+	router.GET("posts/:id", srv.PostGet)
+	router.PUT("posts/:id", srv.PostUpdate)
+	return router
+}
+
+func serve(router *gin.Engine, method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func testPosts() map[uint]models.Post {
+	return map[uint]models.Post{
+		1: {Model: gorm.Model{ID: 1}, Title: "Hello", Body: "world", Format: "plain", AccountID: 7, Version: 3},
+		2: {Model: gorm.Model{ID: 2}, Title: "Secret", Body: "draft", Format: "plain", AccountID: 7, Version: 1, Draft: true},
+	}
+}
+
+func TestPostGetConditional(t *testing.T) {
+	router := newTestRouter(testPosts(), 8)
+
+	w := serve(router, http.MethodGet, "/posts/1", "", nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("GET = %d with ETag %q, want 200 and an ETag", w.Code, etag)
+	}
+	if vary := w.Header().Get("Vary"); vary != "Cookie" {
+		t.Fatalf("Vary = %q, want Cookie", vary)
+	}
+	w = serve(router, http.MethodGet, "/posts/1", "", map[string]string{"If-None-Match": etag})
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("GET with matching If-None-Match = %d, want 304", w.Code)
+	}
+	if w = serve(router, http.MethodGet, "/posts/2", "", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("GET of another account's draft = %d, want 404", w.Code)
+	}
+}
+
+func TestPostGetVariantETag(t *testing.T) {
+	router := newTestRouter(testPosts(), 8)
+	etag := serve(router, http.MethodGet, "/posts/1", "", nil).Header().Get("ETag")
+
+	w := serve(router, http.MethodGet, "/posts/1?body=html", "", map[string]string{"If-None-Match": etag})
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Fatalf("GET ?body=html with the default ETag = %d, ETag %s; want 200 and a different ETag", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestPostUpdatePrecondition(t *testing.T) {
+	posts := testPosts()
+	router := newTestRouter(posts, 7)
+	etag := serve(router, http.MethodGet, "/posts/1", "", nil).Header().Get("ETag")
+	body := `{"title": "Hello again", "body": "world"}`
+
+	w := serve(router, http.MethodPut, "/posts/1", body, map[string]string{"If-Match": `"1-2"`})
+	if w.Code != http.StatusPreconditionFailed || posts[1].Title != "Hello" {
+		t.Fatalf("PUT with stale If-Match = %d, title %q; want 412 and no change", w.Code, posts[1].Title)
+	}
+
+	w = serve(router, http.MethodPut, "/posts/1", body, map[string]string{"If-Match": etag})
+	if w.Code != http.StatusOK || posts[1].Title != "Hello again" || posts[1].Version != 4 {
+		t.Fatalf("PUT with current If-Match = %d, %+v; want 200 and version 4", w.Code, posts[1])
+	}
+	if w.Header().Get("ETag") == etag {
+		t.Fatalf("ETag %s did not change after the update", etag)
+	}
+}
+
+func TestPostUpdateKeepsDraft(t *testing.T) {
+	posts := testPosts()
+	router := newTestRouter(posts, 7)
+
+	w := serve(router, http.MethodPut, "/posts/2", `{"title": "Secret", "body": "still a draft"}`, nil)
+	if w.Code != http.StatusOK || !posts[2].Draft {
+		t.Fatalf("PUT without draft = %d, draft %v; want 200 and still a draft", w.Code, posts[2].Draft)
+	}
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"genesis/models"
+)
+
+// count returns how many rows of model match the query, soft-deleted ones included.
+func (a *apiServer) count(model interface{}, query string, args ...interface{}) int64 {
+	a.t.Helper()
+	var n int64
+	if err := a.db.Unscoped().Model(model).Where(query, args...).Count(&n).Error; err != nil {
+		a.t.Fatalf("count: %v", err)
+	}
+	return n
+}
+
+func TestSQLiteTrashPurge(t *testing.T) {
+	a := newAPIServer(t, "read")
+	alice, bob := a.account("alice"), a.account("bob")
+	post := a.createPost(alice, `{"title": "Hello", "body": "world", "tags": ["notes"]}`)
+	path := postPath(post.ID)
+	a.doJSON(bob, http.MethodPost, path+"/comments", `{"body": "hi"}`, http.StatusCreated, nil)
+	a.doJSON(bob, http.MethodPut, path+"/reactions/like", "", http.StatusOK, nil)
+
+	trashPath := "/trash" + path
+	a.doJSON(alice, http.MethodDelete, trashPath, "", http.StatusNotFound, nil)
+	a.doJSON(alice, http.MethodDelete, path, "", http.StatusOK, nil)
+	a.doJSON(bob, http.MethodDelete, trashPath, "", http.StatusNotFound, nil)
+	a.doJSON(alice, http.MethodDelete, trashPath, "", http.StatusOK, nil)
+
+	if n := a.count(&models.Post{}, "id = ?", post.ID); n != 0 {
+		t.Fatalf("%d post rows left after purge", n)
+	}
+	if n := a.count(&models.Comment{}, "post_id = ?", post.ID); n != 0 {
+		t.Fatalf("%d comments left after purge", n)
+	}
+	if n := a.count(&models.Reaction{}, "post_id = ?", post.ID); n != 0 {
+		t.Fatalf("%d reactions left after purge", n)
+	}
+}
+
+func TestSQLiteAccountPurge(t *testing.T) {
+	a := newAPIServer(t, "read")
+	alice, bob := a.account("alice"), a.account("bob")
+	post := a.createPost(alice, `{"title": "Hello", "body": "world"}`)
+	a.createPost(bob, `{"title": "Bob's", "body": "post"}`)
+	a.doJSON(bob, http.MethodPut, postPath(post.ID)+"/reactions/like", "", http.StatusOK, nil)
+
+	from := models.Wallet{AccountID: &bob, Balance: 60, Currency: "EUR"}
+	to := models.Wallet{AccountID: &alice, Balance: 40, Currency: "EUR"}
+	if err := a.db.Create(&[]*models.Wallet{&from, &to}).Error; err != nil {
+		t.Fatal(err)
+	}
+	transfer := models.Transfer{FromAccountID: uint64(from.ID), ToAccountID: uint64(to.ID), Amount: 40}
+	if err := a.db.Create(&transfer).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.srv.Purger.PurgeAccount(bob); err != nil {
+		t.Fatalf("PurgeAccount: %v", err)
+	}
+	if n := a.count(&models.Account{}, "id = ?", bob); n != 0 {
+		t.Fatal("account left after purge")
+	}
+	if n := a.count(&models.Post{}, "account_id = ?", bob); n != 0 {
+		t.Fatalf("%d posts left after purge", n)
+	}
+
+	// Reactions given on other posts are taken off their counts
+	var resp struct{ Post ResponsePost }
+	a.doJSON(alice, http.MethodGet, postPath(post.ID), "", http.StatusOK, &resp)
+	if resp.Post.Reactions["like"] != 0 {
+		t.Fatalf("likes after the reacting account was purged = %d, want 0", resp.Post.Reactions["like"])
+	}
+
+	// The other side of the transfer keeps its history and balance
+	if n := a.count(&models.Transfer{}, "id = ?", transfer.ID); n != 1 {
+		t.Fatal("transfer to the remaining account removed by the purge")
+	}
+	if wallet, err := a.srv.Store.Wallets().GetByAccount(alice); err != nil || wallet.Balance != 40 {
+		t.Fatalf("recipient balance = %d, %v; want 40", wallet.Balance, err)
+	}
+}
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"genesis/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reactionSet turns the configured reaction types into a lookup set.
+func reactionSet(reactions []string) map[string]bool {
+	allowed := map[string]bool{}
+	for _, r := range reactions {
+		allowed[strings.ToLower(r)] = true
+	}
+	return allowed
+}
+
+// reactionParams validates the post ID and reaction type route params.
+// Posts the caller may not see are reported as missing.
+func (s *Server) reactionParams(c *gin.Context) (uint, string, bool) {
+	postID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(problem.New(problem.BadRequest, "Invalid post ID"))
+		return 0, "", false
+	}
+	reaction := strings.ToLower(c.Param("type"))
+	if !s.reactions[reaction] {
+		c.Error(problem.New(problem.BadRequest, "Unknown reaction"))
+		return 0, "", false
+	}
+	post, ok := s.visiblePost(c, uint(postID))
+	if !ok {
+		return 0, "", false
+	}
+	return post.ID, reaction, true
+}
+
+// ReactionAdd handles PUT requests to react to a post. Repeating the
+// request is a no-op, so the count only moves when a row is inserted.
+func (s *Server) ReactionAdd(c *gin.Context) {
+	accountID, exists := c.Get("accountID")
+	if !exists {
+		c.Error(problem.New(problem.Unauthenticated, "User is unauthenticated"))
+		return
+	}
+	postID, reaction, ok := s.reactionParams(c)
+	if !ok {
+		return
+	}
+
+	if err := s.store(c).Reactions().Add(postID, accountID.(uint), reaction); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to add reaction", "post_id", postID, "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to add reaction"))
+		return
+	}
+	s.respondReactions(c, postID, accountID.(uint))
+}
+
+// ReactionRemove handles DELETE requests to withdraw a reaction. Removing
+// a reaction that isn't there is a no-op.
+func (s *Server) ReactionRemove(c *gin.Context) {
+	accountID, exists := c.Get("accountID")
+	if !exists {
+		c.Error(problem.New(problem.Unauthenticated, "User is unauthenticated"))
+		return
+	}
+	postID, reaction, ok := s.reactionParams(c)
+	if !ok {
+		return
+	}
+
+	if err := s.store(c).Reactions().Remove(postID, accountID.(uint), reaction); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to remove reaction", "post_id", postID, "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to remove reaction"))
+		return
+	}
+	s.respondReactions(c, postID, accountID.(uint))
+}
+
+func (s *Server) respondReactions(c *gin.Context, postID, accountID uint) {
+	resps := []ResponsePost{{ID: postID}}
+	if err := s.attachReactions(c.Request.Context(), resps, accountID); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch reactions", "post_id", postID, "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to fetch reactions"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"reactions":    resps[0].Reactions,
+		"my_reactions": resps[0].MyReactions,
+	})
+}
+
+// attachReactions fills in reaction counts and the caller's own reactions
+// for a batch of posts using one query for each.
+func (s *Server) attachReactions(ctx context.Context, posts []ResponsePost, accountID uint) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	index := make(map[uint]*ResponsePost, len(posts))
+	ids := make([]uint, len(posts))
+	for i := range posts {
+		posts[i].Reactions = map[string]int64{}
+		posts[i].MyReactions = []string{}
+		index[posts[i].ID] = &posts[i]
+		ids[i] = posts[i].ID
+	}
+
+	reactions := s.Store.WithContext(ctx).Reactions()
+	counts, err := reactions.Counts(ids)
+	if err != nil {
+		return err
+	}
+	for _, rc := range counts {
+		index[rc.PostID].Reactions[rc.Type] = rc.Count
+	}
+
+	mine, err := reactions.Mine(ids, accountID)
+	if err != nil {
+		return err
+	}
+	for _, r := range mine {
+		index[r.PostID].MyReactions = append(index[r.PostID].MyReactions, r.Type)
+	}
+	return nil
+}
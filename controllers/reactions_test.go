@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSQLiteReactionCounts(t *testing.T) {
+	a := newAPIServer(t, "read")
+	alice, bob, carol := a.account("alice"), a.account("bob"), a.account("carol")
+	post := a.createPost(alice, `{"title": "Hello", "body": "world"}`)
+	path := postPath(post.ID) + "/reactions/"
+
+	type reactions struct {
+		Reactions   map[string]int64
+		MyReactions []string `json:"my_reactions"`
+	}
+	var resp reactions
+	a.doJSON(bob, http.MethodPut, path+"like", "", http.StatusOK, &resp)
+	a.doJSON(bob, http.MethodPut, path+"like", "", http.StatusOK, &resp)
+	if resp.Reactions["like"] != 1 {
+		t.Fatalf("likes after a repeated reaction = %d, want 1", resp.Reactions["like"])
+	}
+	a.doJSON(carol, http.MethodPut, path+"like", "", http.StatusOK, &resp)
+	a.doJSON(carol, http.MethodPut, path+"wow", "", http.StatusOK, &resp)
+	if resp.Reactions["like"] != 2 || resp.Reactions["wow"] != 1 || len(resp.MyReactions) != 2 {
+		t.Fatalf("reactions = %+v, want 2 likes, 1 wow and both of carol's", resp)
+	}
+
+	a.doJSON(bob, http.MethodDelete, path+"like", "", http.StatusOK, &resp)
+	a.doJSON(bob, http.MethodDelete, path+"like", "", http.StatusOK, &resp)
+	if resp.Reactions["like"] != 1 || len(resp.MyReactions) != 0 {
+		t.Fatalf("reactions after bob withdrew = %+v, want 1 like and none of bob's", resp)
+	}
+
+	var got struct{ Post ResponsePost }
+	a.doJSON(carol, http.MethodGet, postPath(post.ID), "", http.StatusOK, &got)
+	if got.Post.Reactions["like"] != 1 || got.Post.Reactions["wow"] != 1 || len(got.Post.MyReactions) != 2 {
+		t.Fatalf("post reactions = %v, mine %v; want a like and a wow, both carol's", got.Post.Reactions, got.Post.MyReactions)
+	}
+
+	a.doJSON(bob, http.MethodPut, path+"shrug", "", http.StatusBadRequest, nil)
+	draft := a.createPost(alice, `{"title": "Draft", "body": "hidden", "draft": true}`)
+	a.doJSON(bob, http.MethodPut, postPath(draft.ID)+"/reactions/like", "", http.StatusNotFound, nil)
+}
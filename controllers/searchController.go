@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"genesis/models"
+	"genesis/problem"
+	"genesis/search"
+
+	"github.com/gin-gonic/gin"
+)
+
+// indexPost brings the search index in line with a stored post. Failures
+// are logged rather than surfaced since the write itself succeeded.
+func (s *Server) indexPost(post models.Post) {
+	if err := s.Search.Index(search.PostDocument(post)); err != nil {
+		slog.Error("Failed to index post", "post_id", post.ID, "error", err)
+	}
+}
+
+func (s *Server) unindexPost(id uint) {
+	if err := s.Search.Remove(id); err != nil {
+		slog.Error("Failed to remove post from index", "post_id", id, "error", err)
+	}
+}
+
+// PostSearch handles GET requests to search post titles and bodies.
+// Drafts are only returned to their author.
+func (s *Server) PostSearch(c *gin.Context) {
+	accountID, exists := c.Get("accountID")
+	if !exists {
+		c.Error(problem.New(problem.Unauthenticated, "User is unauthenticated"))
+		return
+	}
+	text := strings.TrimSpace(c.Query("q"))
+	if text == "" {
+		c.Error(problem.New(problem.BadRequest, "Missing search query"))
+		return
+	}
+	page, limit := pageParams(c)
+
+	hits, total, err := s.Search.Search(search.Query{
+		Text:     text,
+		ViewerID: accountID.(uint),
+		Limit:    limit,
+		Offset:   (page - 1) * limit,
+	})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Search failed", "query", text, "error", err)
+		c.Error(problem.New(problem.Internal, "Search failed"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": hits,
+		"page":    page,
+		"limit":   limit,
+		"total":   total,
+	})
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"sync"
+
+	"genesis/collab"
+	"genesis/config"
+	"genesis/events"
+	"genesis/problem"
+	"genesis/search"
+	"genesis/storage"
+	"genesis/store"
+	"genesis/timeline"
+	"genesis/workers"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/websocket"
+)
+
+// Validation problems name request fields as clients send them.
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		problem.JSONFieldNames(v)
+	}
+}
+
+// Deps are the services the handlers are built on.
+type Deps struct {
+	Store    store.Store
+	Search   search.Searcher
+	Blobs    storage.BlobStore
+	Timeline timeline.Strategy
+	Events   *events.Hub
+	Collab   *collab.Hub
+	Images   *workers.ImageWorker
+	Purger   *workers.Purger
+}
+
+// Server carries the configuration and dependencies of the HTTP
+// handlers, which are its methods.
+type Server struct {
+	Deps
+
+	cfg       config.Config
+	reactions map[string]bool
+	upgrader  websocket.Upgrader
+
+	imports     chan importTask
+	importsOnce sync.Once
+	importsDone sync.WaitGroup
+	closing     chan struct{}
+
+	importsMu     sync.Mutex
+	importsQueued map[uint]int // queued or running imports by account
+}
+
+// NewServer returns a Server whose handlers follow cfg and use deps.
+func NewServer(cfg config.Config, deps Deps) *Server {
+	return &Server{
+		Deps:      deps,
+		cfg:       cfg,
+		reactions: reactionSet(cfg.Reactions),
+		upgrader:  newUpgrader(cfg.Collab.AllowedOrigins),
+		imports:   make(chan importTask, 16),
+		closing:   make(chan struct{}),
+
+		importsQueued: map[uint]int{},
+	}
+}
+
+// store returns the Store for the request, so its queries are cancelled
+// and traced along with it.
+func (s *Server) store(c *gin.Context) store.Store {
+	return s.Store.WithContext(c.Request.Context())
+}
+
+// Close stops the background import jobs once the current item is
+// done. Call it after the HTTP server has drained, so no handler is left
+// to start new ones.
+func (s *Server) Close() {
+	close(s.closing)
+	s.importsDone.Wait()
+	s.failQueuedImports()
+}
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"genesis/config"
+	"genesis/database"
+	"genesis/events"
+	"genesis/middleware"
+	"genesis/models"
+	"genesis/search"
+	"genesis/storage"
+	"genesis/store"
+	"genesis/timeline"
+	"genesis/workers"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqliteDatabases numbers the in-memory databases, so tests never share one.
+var sqliteDatabases atomic.Int64
+
+// apiServer serves the handlers over an in-memory SQLite database.
+// Requests name their account in the X-Account header instead of
+// carrying a token.
+type apiServer struct {
+	t      *testing.T
+	db     *gorm.DB
+	srv    *Server
+	router *gin.Engine
+}
+
+// newAPIServer builds an apiServer whose home timelines use strategy.
+func newAPIServer(t *testing.T, strategy string) *apiServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, err := database.Open(database.Config{
+		Driver:       database.DriverSQLite,
+		DSN:          fmt.Sprintf("file:test%d?mode=memory&cache=shared", sqliteDatabases.Add(1)),
+		MaxOpenConns: 1,
+		Logger:       logger.Discard,
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	blobs, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("open blob store: %v", err)
+	}
+	hub, err := events.NewHub(events.NewMemoryBroker(), 16)
+	if err != nil {
+		t.Fatalf("open event hub: %v", err)
+	}
+	var home timeline.Strategy = timeline.NewReadStrategy(db)
+	if strategy == "write" {
+		home = timeline.NewWriteStrategy(db)
+	}
+	index := search.NewMemoryIndex()
+
+	cfg := config.Default()
+	cfg.Imports.SpoolDir = t.TempDir()
+	srv := NewServer(cfg, Deps{
+		Store:    store.NewGorm(db),
+		Search:   index,
+		Blobs:    blobs,
+		Timeline: home,
+		Events:   hub,
+		Purger:   workers.NewPurger(db, blobs, index, home, time.Hour),
+	})
+	t.Cleanup(func() {
+		srv.Close()
+		hub.Close()
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	router := gin.New()
+	router.Use(middleware.Errors(), func(c *gin.Context) {
+		if id, err := strconv.ParseUint(c.GetHeader("X-Account"), 10, 64); err == nil {
+			c.Set("accountID", uint(id))
+		}
+	})
+	router.DELETE("account/", srv.AccountDelete)
+	router.GET("account/export", srv.AccountExport)
+	router.POST("account/import", srv.AccountImport)
+	router.GET("account/imports/:id", srv.ImportGet)
+	router.POST("accounts/:handle/follow", srv.AccountFollow)
+	router.GET("timeline/", srv.TimelineHome)
+	router.POST("posts/", srv.PostsCreate)
+	router.GET("posts/:id", srv.PostGet)
+	router.PUT("posts/:id", srv.PostUpdate)
+	router.GET("posts/", srv.PostList)
+	router.DELETE("posts/:id", srv.PostDelete)
+	router.GET("authors/:handle/posts/:slug", srv.PostPermalink)
+	router.GET("search/", srv.PostSearch)
+	router.GET("feed/:format", srv.SiteFeed)
+	router.DELETE("trash/posts/:id", srv.TrashPurge)
+	router.POST("attachments/", srv.AttachmentUpload)
+	router.POST("posts/:id/comments", srv.CommentCreate)
+	router.GET("posts/:id/comments", srv.CommentList)
+	router.PUT("posts/:id/reactions/:type", srv.ReactionAdd)
+	router.DELETE("posts/:id/reactions/:type", srv.ReactionRemove)
+
+	return &apiServer{t: t, db: db, srv: srv, router: router}
+}
+
+// account creates an account with handle and returns its ID.
+func (a *apiServer) account(handle string) uint {
+	a.t.Helper()
+	account := models.Account{Email: handle + "@example.com", Handle: handle}
+	if err := a.db.Create(&account).Error; err != nil {
+		a.t.Fatalf("create account %s: %v", handle, err)
+	}
+	return account.ID
+}
+
+// do serves one request as accountID, or anonymously when it is 0.
+func (a *apiServer) do(accountID uint, method, path string, body io.Reader, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Content-Type", "application/json")
+	if accountID != 0 {
+		req.Header.Set("X-Account", strconv.FormatUint(uint64(accountID), 10))
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+	return w
+}
+
+// doJSON serves a request with a JSON body and decodes the response into
+// out, failing unless it has the wanted status.
+func (a *apiServer) doJSON(accountID uint, method, path, body string, status int, out interface{}) *httptest.ResponseRecorder {
+	a.t.Helper()
+	w := a.do(accountID, method, path, strings.NewReader(body), nil)
+	if w.Code != status {
+		a.t.Fatalf("%s %s = %d, want %d: %s", method, path, w.Code, status, w.Body)
+	}
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			a.t.Fatalf("%s %s: decode %s: %v", method, path, w.Body, err)
+		}
+	}
+	return w
+}
+
+// createPost creates a post from a JSON body as accountID.
+func (a *apiServer) createPost(accountID uint, body string) ResponsePost {
+	a.t.Helper()
+	var resp struct{ Post ResponsePost }
+	a.doJSON(accountID, http.MethodPost, "/posts/", body, http.StatusCreated, &resp)
+	return resp.Post
+}
+
+func postPath(id uint) string {
+	return "/posts/" + strconv.FormatUint(uint64(id), 10)
+}
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"genesis/models"
+	"genesis/problem"
+	"genesis/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestTagRename represents the expected JSON payload for renaming a tag.
+type RequestTagRename struct {
+	Name string `json:"name" binding:"required,max=64"`
+}
+
+// RequestTagMerge represents the expected JSON payload for merging a tag into another.
+type RequestTagMerge struct {
+	Into string `json:"into" binding:"required,max=64"`
+}
+
+// ResponseTag represents a tag together with the number of posts using it.
+type ResponseTag struct {
+	Slug  string `json:"slug"`
+	Count int64  `json:"count"`
+}
+
+func tagSlugs(tags []models.Tag) []string {
+	slugs := make([]string, len(tags))
+	for i, tag := range tags {
+		slugs[i] = tag.Slug
+	}
+	return slugs
+}
+
+// findTag loads one of the caller's tags by the :slug route param.
+func (s *Server) findTag(c *gin.Context) (models.Tag, bool) {
+	accountID, exists := c.Get("accountID")
+	if !exists {
+		c.Error(problem.New(problem.Unauthenticated, "User is unauthenticated"))
+		return models.Tag{}, false
+	}
+	tag, err := s.store(c).Tags().Get(accountID.(uint), store.TagSlug(c.Param("slug")))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.Error(problem.New(problem.NotFound, "Tag not found"))
+			return tag, false
+		}
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch tag", "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to fetch tag"))
+		return tag, false
+	}
+	return tag, true
+}
+
+// TagList handles GET requests for the caller's tags and their post counts.
+func (s *Server) TagList(c *gin.Context) {
+	accountID, exists := c.Get("accountID")
+	if !exists {
+		c.Error(problem.New(problem.Unauthenticated, "User is unauthenticated"))
+		return
+	}
+
+	counts, err := s.store(c).Tags().List(accountID.(uint))
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch tags", "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to fetch tags"))
+		return
+	}
+	tags := make([]ResponseTag, len(counts))
+	for i, count := range counts {
+		tags[i] = ResponseTag(count)
+	}
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+// TagPosts handles GET requests for the caller's posts under a tag.
+func (s *Server) TagPosts(c *gin.Context) {
+	tag, ok := s.findTag(c)
+	if !ok {
+		return
+	}
+
+	posts, err := s.store(c).Posts().List(store.PostFilter{AccountID: tag.AccountID, Tags: []string{tag.Slug}})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch posts for tag", "tag", tag.Slug, "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to fetch posts"))
+		return
+	}
+
+	variant := bodyVariant(c)
+	resps := make([]ResponsePost, len(posts))
+	for i, post := range posts {
+		resps[i] = newResponsePost(post, variant)
+	}
+	if err := s.attachReactions(c.Request.Context(), resps, tag.AccountID); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch reactions", "error", err)
+	}
+	c.JSON(http.StatusOK, gin.H{"posts": resps})
+}
+
+// TagRename handles PUT requests to rename one of the caller's tags.
+func (s *Server) TagRename(c *gin.Context) {
+	tag, ok := s.findTag(c)
+	if !ok {
+		return
+	}
+	var req RequestTagRename
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(problem.Invalid(err))
+		return
+	}
+	slug := store.TagSlug(req.Name)
+	if slug == "" {
+		c.Error(problem.New(problem.BadRequest, "Invalid tag name"))
+		return
+	}
+
+	existing, err := s.store(c).Tags().Get(tag.AccountID, slug)
+	if err == nil && existing.ID != tag.ID {
+		c.Error(problem.New(problem.Conflict, "Tag already exists, merge instead"))
+		return
+	}
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch tag", "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to rename tag"))
+		return
+	}
+	err = s.store(c).Tags().Rename(tag, slug)
+	if errors.Is(err, store.ErrConflict) {
+		c.Error(problem.New(problem.Conflict, "Tag already exists, merge instead"))
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to rename tag", "tag_id", tag.ID, "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to rename tag"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tag": slug})
+}
+
+// TagMerge handles POST requests to fold one of the caller's tags into
+// another. Posts carrying the source tag get the target and the source is removed.
+func (s *Server) TagMerge(c *gin.Context) {
+	source, ok := s.findTag(c)
+	if !ok {
+		return
+	}
+	var req RequestTagMerge
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(problem.Invalid(err))
+		return
+	}
+	target, err := s.store(c).Tags().Get(source.AccountID, store.TagSlug(req.Into))
+	if err != nil {
+		c.Error(problem.New(problem.NotFound, "Target tag not found"))
+		return
+	}
+	if target.ID == source.ID {
+		c.Error(problem.New(problem.BadRequest, "Cannot merge a tag into itself"))
+		return
+	}
+
+	if err := s.store(c).Tags().Merge(source, target); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to merge tag", "tag_id", source.ID, "into_tag_id", target.ID, "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to merge tags"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tag": target.Slug})
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestSQLiteTimelineCursor(t *testing.T) {
+	for _, strategy := range []string{"read", "write"} {
+		t.Run(strategy, func(t *testing.T) {
+			a := newAPIServer(t, strategy)
+			alice, bob, carol := a.account("alice"), a.account("bob"), a.account("carol")
+			a.doJSON(bob, http.MethodPost, "/accounts/alice/follow", "", http.StatusOK, nil)
+
+			var want []uint
+			for i := 0; i < 5; i++ {
+				post := a.createPost(alice, fmt.Sprintf(`{"title": "Post %d", "body": "text"}`, i))
+				want = append([]uint{post.ID}, want...)
+			}
+			a.createPost(alice, `{"title": "Draft", "body": "hidden", "draft": true}`)
+			a.createPost(carol, `{"title": "Unfollowed", "body": "text"}`)
+
+			var got []uint
+			cursor := ""
+			for pages := 1; ; pages++ {
+				var page struct {
+					Posts      []ResponsePost
+					NextCursor string `json:"next_cursor"`
+				}
+				a.doJSON(bob, http.MethodGet, "/timeline/?limit=2&cursor="+url.QueryEscape(cursor), "", http.StatusOK, &page)
+				for _, post := range page.Posts {
+					got = append(got, post.ID)
+				}
+				if cursor = page.NextCursor; cursor == "" {
+					break
+				}
+				if pages > len(want) {
+					t.Fatal("timeline never ran out of pages")
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("timeline = %v, want %v", got, want)
+			}
+
+			a.doJSON(bob, http.MethodGet, "/timeline/?cursor=not-a-cursor", "", http.StatusBadRequest, nil)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"genesis/models"
+	"genesis/problem"
+	"genesis/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResponseTrashedPost is a post in the trash, with the time the purge
+// will remove it for good.
+type ResponseTrashedPost struct {
+	ResponsePost
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+// findTrashedPost loads one of the caller's soft-deleted posts named by
+// the :id path parameter.
+func (s *Server) findTrashedPost(c *gin.Context) (models.Post, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(problem.New(problem.BadRequest, "Invalid post ID"))
+		return models.Post{}, false
+	}
+	post, err := s.store(c).Posts().GetTrashed(c.GetUint("accountID"), uint(id))
+	if err != nil {
+		c.Error(problem.New(problem.NotFound, "Post not found in trash"))
+		return post, false
+	}
+	return post, true
+}
+
+// TrashList handles GET requests to list the caller's deleted posts,
+// most recently deleted first.
+func (s *Server) TrashList(c *gin.Context) {
+	accountID := c.GetUint("accountID")
+	page, limit := pageParams(c)
+
+	posts, err := s.store(c).Posts().Trashed(accountID, (page-1)*limit, limit)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch trash", "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to fetch trash"))
+		return
+	}
+
+	retention := s.cfg.Trash.Retention
+	variant := bodyVariant(c)
+	resps := make([]ResponseTrashedPost, len(posts))
+	for i, post := range posts {
+		resps[i] = ResponseTrashedPost{
+			ResponsePost: newResponsePost(post, variant),
+			DeletedAt:    post.DeletedAt.Time,
+			PurgeAt:      post.DeletedAt.Time.Add(retention),
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"posts": resps,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// TrashRestore handles POST requests to bring a deleted post back. The
+// post keeps its slug, which stays reserved while it is in the trash.
+func (s *Server) TrashRestore(c *gin.Context) {
+	post, ok := s.findTrashedPost(c)
+	if !ok {
+		return
+	}
+	post, err := s.store(c).Posts().Restore(post)
+	if errors.Is(err, store.ErrNotFound) {
+		c.Error(problem.New(problem.NotFound, "Post not found in trash"))
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to restore post", "post_id", post.ID, "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to restore post"))
+		return
+	}
+	s.indexPost(post)
+	s.notifyTimeline(post)
+
+	s.respondPost(c, http.StatusOK, post, post.AccountID)
+}
+
+// TrashPurge handles DELETE requests to permanently delete a post from the trash.
+func (s *Server) TrashPurge(c *gin.Context) {
+	post, ok := s.findTrashedPost(c)
+	if !ok {
+		return
+	}
+	if err := s.Purger.PurgePost(post.ID); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to purge post", "post_id", post.ID, "error", err)
+		c.Error(problem.New(problem.Internal, "Unable to delete post"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Post Permanently Deleted"})
+}
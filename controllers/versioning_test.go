@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSQLitePostVersioning(t *testing.T) {
+	a := newAPIServer(t, "read")
+	alice, bob := a.account("alice"), a.account("bob")
+	post := a.createPost(alice, `{"title": "Hello", "body": "world"}`)
+	path := postPath(post.ID)
+
+	w := a.doJSON(alice, http.MethodGet, path, "", http.StatusOK, nil)
+	etag := w.Header().Get("ETag")
+	if w = a.do(alice, http.MethodGet, path, nil, map[string]string{"If-None-Match": etag}); w.Code != http.StatusNotModified {
+		t.Fatalf("GET with current ETag = %d, want 304", w.Code)
+	}
+
+	// A reaction changes the representation but not the version
+	a.doJSON(bob, http.MethodPut, path+"/reactions/like", "", http.StatusOK, nil)
+	w = a.doJSON(alice, http.MethodGet, path, "", http.StatusOK, nil)
+	if w.Header().Get("ETag") == etag {
+		t.Fatal("ETag did not change after a reaction")
+	}
+
+	body := `{"title": "Hello again", "body": "world"}`
+	w = a.do(alice, http.MethodPut, path, strings.NewReader(body), map[string]string{"If-Match": etag})
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT with the current version's ETag = %d, want 200: %s", w.Code, w.Body)
+	}
+	var updated struct{ Post ResponsePost }
+	json.Unmarshal(w.Body.Bytes(), &updated)
+	if updated.Post.Version != post.Version+1 {
+		t.Fatalf("version after update = %d, want %d", updated.Post.Version, post.Version+1)
+	}
+	w = a.do(alice, http.MethodPut, path, strings.NewReader(body), map[string]string{"If-Match": etag})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("PUT with a stale ETag = %d, want 412", w.Code)
+	}
+	w = a.do(alice, http.MethodDelete, path, nil, map[string]string{"If-Match": etag})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("DELETE with a stale ETag = %d, want 412", w.Code)
+	}
+}
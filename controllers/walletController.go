@@ -1,14 +1,63 @@
 package controllers
 
-import "time"
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
 
+	"genesis/models"
+	"genesis/problem"
+	"genesis/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WalletBody represents the response structure for a wallet. Balance is
+// in minor units of the currency, e.g. cents.
 type WalletBody struct {
 	ID        uint      `json:"id"`
-	Balance   float64   `json:"balance"`
+	Balance   int64     `json:"balance"`
 	Currency  string    `json:"currency"`
 	AccountID uint      `json:"accountID"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-// Add Transfer and Entries from SIMPLE BANK postgres file
+func newWalletBody(wallet models.Wallet) WalletBody {
+	body := WalletBody{
+		ID:        wallet.ID,
+		Balance:   wallet.Balance,
+		Currency:  wallet.Currency,
+		CreatedAt: wallet.CreatedAt,
+		UpdatedAt: wallet.UpdatedAt,
+	}
+	if wallet.AccountID != nil {
+		body.AccountID = *wallet.AccountID
+	}
+	return body
+}
+
+// findWallet loads the caller's wallet.
+func (s *Server) findWallet(c *gin.Context) (models.Wallet, bool) {
+	wallet, err := s.store(c).Wallets().GetByAccount(c.GetUint("accountID"))
+	if errors.Is(err, store.ErrNotFound) {
+		c.Error(problem.New(problem.NotFound, "Wallet not found"))
+		return wallet, false
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch wallet", "error", err)
+		c.Error(problem.New(problem.Internal, "Failed to fetch wallet"))
+		return wallet, false
+	}
+	return wallet, true
+}
+
+// WalletGet handles GET requests for the caller's wallet.
+func (s *Server) WalletGet(c *gin.Context) {
+	wallet, ok := s.findWallet(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"wallet": newWalletBody(wallet)})
+}
@@ -0,0 +1,116 @@
+// Package database opens the GORM connection on one of the supported
+// drivers and creates the schema.
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Supported drivers.
+const (
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+	DriverSQLite   = "sqlite"
+)
+
+// Config selects the driver and tunes the connection pool. Zero pool
+// settings keep the database/sql defaults.
+type Config struct {
+	Driver string
+	// DSN is the driver's connection string. For SQLite it is a file
+	// path or URI, with ":memory:" (or empty) for an in-memory database.
+	DSN             string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// Logger receives GORM's logs; nil keeps GORM's default logger.
+	Logger logger.Interface
+}
+
+// Memory reports whether cfg names an in-memory SQLite database, which
+// lives only as long as the process and so has to be migrated on start.
+func (cfg Config) Memory() bool {
+	return cfg.Driver == DriverSQLite &&
+		(cfg.DSN == "" || strings.Contains(cfg.DSN, ":memory:") || strings.Contains(cfg.DSN, "mode=memory"))
+}
+
+// Open connects to the database described by cfg and applies the pool settings.
+func Open(cfg Config) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch cfg.Driver {
+	case "", DriverPostgres:
+		dialector = postgres.Open(cfg.DSN)
+	case DriverMySQL:
+		dialector = mysql.Open(withParams(cfg.DSN, "parseTime=true"))
+	case DriverSQLite:
+		dialector = sqlite.Open(sqliteDSN(cfg))
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", cfg.Driver)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: cfg.Logger, TranslateError: true})
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	// An in-memory database disappears with its last connection, so
+	// those are never retired.
+	if !cfg.Memory() {
+		sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+		sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+	return db, nil
+}
+
+// sqliteDSN turns a path or ":memory:" into a URI that shares one
+// in-memory database between pooled connections and enables the foreign
+// keys the cascading deletes rely on.
+func sqliteDSN(cfg Config) string {
+	dsn := cfg.DSN
+	if dsn == "" || dsn == ":memory:" {
+		dsn = "file::memory:?cache=shared"
+	}
+	dsn = withParams(dsn, "_pragma=foreign_keys(1)", "_pragma=busy_timeout(5000)")
+	if !cfg.Memory() {
+		dsn = withParams(dsn, "_pragma=journal_mode(WAL)")
+	}
+	return dsn
+}
+
+// withParams appends the query parameters whose name dsn doesn't set yet.
+func withParams(dsn string, params ...string) string {
+	for _, param := range params {
+		name, value, _ := strings.Cut(param, "=")
+		if pragma, _, ok := strings.Cut(value, "("); ok {
+			// Pragmas share one parameter name, so look for the pragma itself
+			name = pragma
+		}
+		if strings.Contains(dsn, name) {
+			continue
+		}
+		if strings.Contains(dsn, "?") {
+			dsn += "&" + param
+		} else {
+			dsn += "?" + param
+		}
+	}
+	return dsn
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"genesis/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SchemaVersion is the version of the schema Migrate creates. Raise it
+// whenever a model changes, so instances running against an older schema
+// report themselves as not ready until the migration has run.
+const SchemaVersion = 3
+
+// schemaMigration records a schema version applied to the database.
+type schemaMigration struct {
+	Version   int `gorm:"primarykey;autoIncrement:false"`
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrate creates or updates the tables of every model and records
+// SchemaVersion.
+func Migrate(db *gorm.DB) error {
+	applied, err := Version(db.Statement.Context, db)
+	if err != nil {
+		return err
+	}
+	err = db.AutoMigrate(
+		&models.Post{},
+		&models.Account{},
+		&models.Comment{},
+		&models.Reaction{}, &models.ReactionCount{},
+		&models.Tag{},
+		&models.Attachment{}, &models.AttachmentVariant{},
+		&models.PostSlugRedirect{},
+		&models.Follow{}, &models.TimelineEntry{},
+		&models.ImportJob{},
+		&models.Wallet{}, &models.Entry{}, &models.Transfer{},
+		&models.PostCollaborator{},
+		&schemaMigration{},
+	)
+	if err != nil {
+		return err
+	}
+	if applied < 3 {
+		if err := restrictTransfers(db); err != nil {
+			return err
+		}
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&schemaMigration{Version: SchemaVersion, AppliedAt: time.Now()}).Error
+}
+
+// restrictTransfers re-creates the foreign keys from transfers to wallets,
+// which version 3 changed from ON DELETE CASCADE to RESTRICT. AutoMigrate
+// only adds missing constraints, so it never alters the existing ones.
+func restrictTransfers(db *gorm.DB) error {
+	migrator := db.Migrator()
+	for _, name := range []string{"SentTransfers", "ReceivedTransfers"} {
+		if migrator.HasConstraint(&models.Wallet{}, name) {
+			if err := migrator.DropConstraint(&models.Wallet{}, name); err != nil {
+				return err
+			}
+		}
+		if err := migrator.CreateConstraint(&models.Wallet{}, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Version returns the latest schema version applied to the database, or
+// 0 when it was never migrated by a version-aware build.
+func Version(ctx context.Context, db *gorm.DB) (int, error) {
+	db = db.WithContext(ctx)
+	if !db.Migrator().HasTable(&schemaMigration{}) {
+		return 0, nil
+	}
+	var version int
+	err := db.Model(&schemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"testing"
+
+	"genesis/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// cascadeWallet is the wallets table as schema version 2 created it,
+// with transfers deleted along with their wallets.
+type cascadeWallet struct {
+	gorm.Model
+	AccountID         *uint
+	Balance           int64
+	Currency          string
+	SentTransfers     []models.Transfer `gorm:"foreignKey:FromAccountID;constraint:OnDelete:CASCADE"`
+	ReceivedTransfers []models.Transfer `gorm:"foreignKey:ToAccountID;constraint:OnDelete:CASCADE"`
+}
+
+func (cascadeWallet) TableName() string {
+	return "wallets"
+}
+
+func TestMigrateRestrictsTransfers(t *testing.T) {
+	db, err := Open(Config{Driver: DriverSQLite, DSN: ":memory:", MaxOpenConns: 1, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&cascadeWallet{}, &models.Transfer{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	wallets := []models.Wallet{{Currency: "EUR"}, {Currency: "EUR"}}
+	if err := db.Create(&wallets).Error; err != nil {
+		t.Fatal(err)
+	}
+	transfer := models.Transfer{FromAccountID: uint64(wallets[0].ID), ToAccountID: uint64(wallets[1].ID), Amount: 1}
+	if err := db.Create(&transfer).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Unscoped().Delete(&wallets[0]).Error; err == nil {
+		t.Fatal("deleted a wallet that transfers point at")
+	}
+	var n int64
+	db.Model(&models.Transfer{}).Count(&n)
+	if n != 1 {
+		t.Fatalf("%d transfers after deleting a wallet, want 1", n)
+	}
+}
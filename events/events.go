@@ -0,0 +1,56 @@
+// Package events delivers real-time notifications to accounts. Events go
+// through a Broker so that every server instance sees them, and a Hub on
+// each instance fans them out to connected clients and keeps a bounded
+// replay buffer for clients that reconnect.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Event types.
+const (
+	TypePostPublished    = "post.published"
+	TypeCommentCreated   = "comment.created"
+	TypeTransferReceived = "transfer.received"
+)
+
+// Event is a notification for one or more accounts. The broker assigns
+// IDs that sort in publication order, which is what resuming from
+// Last-Event-ID relies on.
+type Event struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Recipients []uint          `json:"recipients"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// New builds an event with data encoded as JSON. Its ID is assigned when
+// it is published.
+func New(typ string, recipients []uint, data interface{}) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{Type: typ, Recipients: recipients, Data: raw}, nil
+}
+
+// formatID pads a sequence number in hex so IDs compare as strings.
+func formatID(seq uint64) string {
+	return fmt.Sprintf("%016x", seq)
+}
+
+// Broker carries events between server instances.
+type Broker interface {
+	// Publish assigns the event the next ID in sequence and sends it to
+	// every subscriber on every instance.
+	Publish(ctx context.Context, event Event) error
+	// Subscribe calls handler for each published event until the
+	// returned function is called.
+	Subscribe(handler func(Event)) (unsubscribe func(), err error)
+	// Ping reports whether the broker can carry events right now.
+	Ping(ctx context.Context) error
+	Close() error
+}
@@ -0,0 +1,152 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// subscriberBuffer is how many events a slow client may fall behind
+// before it is disconnected; it then resumes from the replay buffer.
+const subscriberBuffer = 64
+
+// Hub fans events out to the clients connected to this instance and
+// remembers the most recent ones for replay.
+type Hub struct {
+	broker      Broker
+	unsubscribe func()
+
+	mu     sync.Mutex
+	replay []Event // ring of the latest events, oldest at start
+	start  int
+	size   int
+	subs   map[uint]map[chan Event]struct{}
+}
+
+// NewHub subscribes to broker and keeps the last replaySize events.
+func NewHub(broker Broker, replaySize int) (*Hub, error) {
+	h := &Hub{
+		broker: broker,
+		replay: make([]Event, 0, replaySize),
+		size:   replaySize,
+		subs:   map[uint]map[chan Event]struct{}{},
+	}
+	unsubscribe, err := broker.Subscribe(h.deliver)
+	if err != nil {
+		return nil, err
+	}
+	h.unsubscribe = unsubscribe
+	return h, nil
+}
+
+// Publish sends an event through the broker.
+func (h *Hub) Publish(ctx context.Context, event Event) error {
+	return h.broker.Publish(ctx, event)
+}
+
+// Ping reports whether the broker can carry events.
+func (h *Hub) Ping(ctx context.Context) error {
+	return h.broker.Ping(ctx)
+}
+
+// Send builds an event and publishes it to recipients. Notifications are
+// best effort, so failures are only logged.
+func (h *Hub) Send(typ string, recipients []uint, data interface{}) {
+	if len(recipients) == 0 {
+		return
+	}
+	event, err := New(typ, recipients, data)
+	if err == nil {
+		err = h.Publish(context.Background(), event)
+	}
+	if err != nil {
+		slog.Error("Failed to publish event", "type", typ, "error", err)
+	}
+}
+
+func (h *Hub) deliver(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.replay) < h.size {
+		h.replay = append(h.replay, event)
+	} else {
+		h.replay[h.start] = event
+		h.start = (h.start + 1) % h.size
+	}
+	for _, recipient := range event.Recipients {
+		for ch := range h.subs[recipient] {
+			select {
+			case ch <- event:
+			default:
+				h.drop(recipient, ch)
+			}
+		}
+	}
+}
+
+// Subscribe registers a client of accountID. It returns the buffered
+// events for the account published after lastID (none when lastID is
+// empty), then live events on the channel. The channel is closed when
+// the client falls too far behind or cancel is called.
+func (h *Hub) Subscribe(accountID uint, lastID string) (missed []Event, live <-chan Event, cancel func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if lastID != "" {
+		for i := range h.replay {
+			event := h.replay[(h.start+i)%len(h.replay)]
+			if event.ID > lastID && addressedTo(event, accountID) {
+				missed = append(missed, event)
+			}
+		}
+	}
+	ch := make(chan Event, subscriberBuffer)
+	if h.subs[accountID] == nil {
+		h.subs[accountID] = map[chan Event]struct{}{}
+	}
+	h.subs[accountID][ch] = struct{}{}
+	return missed, ch, func() {
+		h.mu.Lock()
+		h.drop(accountID, ch)
+		h.mu.Unlock()
+	}
+}
+
+// drop removes and closes a subscriber channel; h.mu must be held.
+func (h *Hub) drop(accountID uint, ch chan Event) {
+	if _, ok := h.subs[accountID][ch]; !ok {
+		return
+	}
+	delete(h.subs[accountID], ch)
+	if len(h.subs[accountID]) == 0 {
+		delete(h.subs, accountID)
+	}
+	close(ch)
+}
+
+// Disconnect ends the stream of every connected client. Events can
+// still be published, e.g. by requests that are finishing.
+func (h *Hub) Disconnect() {
+	h.mu.Lock()
+	for accountID, chans := range h.subs {
+		for ch := range chans {
+			h.drop(accountID, ch)
+		}
+	}
+	h.mu.Unlock()
+}
+
+// Close detaches the hub from its broker and disconnects every client.
+func (h *Hub) Close() error {
+	h.unsubscribe()
+	h.Disconnect()
+	return h.broker.Close()
+}
+
+func addressedTo(event Event, accountID uint) bool {
+	for _, recipient := range event.Recipients {
+		if recipient == accountID {
+			return true
+		}
+	}
+	return false
+}
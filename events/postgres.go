@@ -0,0 +1,177 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// postgresChannel is the LISTEN/NOTIFY channel event IDs travel on.
+	postgresChannel = "genesis_events"
+	// postgresRetention is how long published events are kept for
+	// listeners to load, and postgresPruneEvery how many events are
+	// published between removals of older ones.
+	postgresRetention  = time.Hour
+	postgresPruneEvery = 1000
+	// postgresPublishLock is the advisory lock publishers hold from
+	// taking an ID until they commit.
+	postgresPublishLock = 0x67656e65736973
+)
+
+// postgresSchema creates the table events are published through.
+const postgresSchema = `CREATE TABLE IF NOT EXISTS genesis_events (
+	id BIGSERIAL PRIMARY KEY,
+	type TEXT NOT NULL,
+	recipients JSONB NOT NULL,
+	data JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+
+// PostgresBroker shares events between instances through PostgreSQL
+// LISTEN/NOTIFY, so no extra infrastructure is needed. Events are
+// written to a table whose sequence numbers them, and NOTIFY carries
+// only the ID, since its payload is limited to 8000 bytes and an event
+// to every follower of an account can be far larger. Publishers take
+// turns, so IDs follow commit order and a client resuming after an ID
+// never skips an event that committed late.
+type PostgresBroker struct {
+	pool   *pgxpool.Pool
+	ctx    context.Context
+	cancel context.CancelFunc
+	local  *MemoryBroker
+	once   sync.Once
+	// listening is set while the listener connection is in LISTEN mode.
+	listening atomic.Bool
+}
+
+func NewPostgresBroker(dsn string) (*PostgresBroker, error) {
+	pool, err := pgxpool.New(context.Background(), dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := pool.Exec(context.Background(), postgresSchema); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &PostgresBroker{pool: pool, ctx: ctx, cancel: cancel, local: NewMemoryBroker()}, nil
+}
+
+// Publish stores the event and notifies listeners of its ID in the same
+// statement, so the notification is only sent once the row is visible.
+// The sequence alone numbers events in insert order, which concurrent
+// publishers may commit out of, so the transaction holds an advisory lock
+// from the insert until it commits.
+func (b *PostgresBroker) Publish(ctx context.Context, event Event) error {
+	recipients, err := json.Marshal(event.Recipients)
+	if err != nil {
+		return err
+	}
+	var id int64
+	err = pgx.BeginFunc(ctx, b.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", postgresPublishLock); err != nil {
+			return err
+		}
+		return tx.QueryRow(ctx, `WITH event AS (
+			INSERT INTO genesis_events (type, recipients, data) VALUES ($2, $3, $4) RETURNING id
+		) SELECT id FROM event, pg_notify($1, id::text)`,
+			postgresChannel, event.Type, recipients, []byte(event.Data)).Scan(&id)
+	})
+	if err != nil {
+		return err
+	}
+	if id%postgresPruneEvery == 0 {
+		if _, err := b.pool.Exec(ctx, "DELETE FROM genesis_events WHERE created_at < $1",
+			time.Now().Add(-postgresRetention)); err != nil {
+			slog.Warn("Failed to prune published events", "error", err)
+		}
+	}
+	return nil
+}
+
+// Subscribe starts the listener on first use; notifications are then
+// handed to local subscribers.
+func (b *PostgresBroker) Subscribe(handler func(Event)) (func(), error) {
+	b.once.Do(func() { go b.listen() })
+	return b.local.Subscribe(handler)
+}
+
+// listen holds a dedicated connection in LISTEN mode and reconnects
+// after failures. Events published while it is down are lost, which
+// clients notice as a gap in the replay.
+func (b *PostgresBroker) listen() {
+	for b.ctx.Err() == nil {
+		if err := b.listenOnce(); err != nil && b.ctx.Err() == nil {
+			slog.Warn("Event listener failed, reconnecting", "error", err)
+			time.Sleep(time.Second)
+		}
+	}
+}
+
+func (b *PostgresBroker) listenOnce() error {
+	conn, err := b.pool.Acquire(b.ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(b.ctx, "LISTEN "+postgresChannel); err != nil {
+		return err
+	}
+	b.listening.Store(true)
+	defer b.listening.Store(false)
+	for {
+		n, err := conn.Conn().WaitForNotification(b.ctx)
+		if err != nil {
+			return err
+		}
+		id, err := strconv.ParseUint(n.Payload, 10, 64)
+		if err != nil {
+			slog.Warn("Dropping malformed event notification", "payload", n.Payload)
+			continue
+		}
+		event := Event{ID: formatID(id)}
+		var recipients, data []byte
+		err = conn.QueryRow(b.ctx, "SELECT type, recipients, data FROM genesis_events WHERE id = $1", id).
+			Scan(&event.Type, &recipients, &data)
+		if errors.Is(err, pgx.ErrNoRows) {
+			slog.Warn("Dropping pruned event", "id", id)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(recipients, &event.Recipients); err != nil {
+			slog.Warn("Dropping malformed event", "id", id, "error", err)
+			continue
+		}
+		event.Data = data
+		b.local.deliver(event)
+	}
+}
+
+// Ping checks the database and that the listener is connected, since
+// events published while it reconnects never reach this instance.
+func (b *PostgresBroker) Ping(ctx context.Context) error {
+	if err := b.pool.Ping(ctx); err != nil {
+		return err
+	}
+	if !b.listening.Load() {
+		return errors.New("event listener is not connected")
+	}
+	return nil
+}
+
+func (b *PostgresBroker) Close() error {
+	b.cancel()
+	b.pool.Close()
+	return nil
+}
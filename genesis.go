@@ -1,12 +1,26 @@
 package main
 
 import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"genesis/config"
 	"genesis/controllers"
 	"genesis/initializers"
+	"genesis/logging"
+	"genesis/metrics"
 	"genesis/middleware"
+	"genesis/ratelimit"
+	"genesis/server"
+	"genesis/store"
+	"genesis/tracing"
+	"genesis/workers"
 
 	"github.com/gin-gonic/gin"
-	_ "github.com/go-sql-driver/mysql"
 )
 
 // func main() {
@@ -181,26 +195,195 @@ import (
 //		}
 //		fmt.Printf("Response: %T\n", string(body))
 //	}
-func init() {
-	initializers.LoadEnvVariables()
-	initializers.ConnectDB()
-}
 func main() {
-	router := gin.Default()
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	logger := logging.New(cfg.Log, os.Stderr)
+	slog.SetDefault(logger)
+	// The log package is left for fatal startup errors
+	slog.SetLogLoggerLevel(slog.LevelError)
+	slog.Info("Loaded configuration", "config", cfg)
+	shutdownTracing, err := tracing.Setup(cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Could not set up tracing: %v", err)
+	}
+
+	gormDB := initializers.ConnectDB(cfg.Database)
+	if err := gormDB.Use(metrics.GormPlugin{}); err != nil {
+		log.Fatalf("Could not instrument Database: %v", err)
+	}
+	if err := gormDB.Use(tracing.GormPlugin{}); err != nil {
+		log.Fatalf("Could not instrument Database: %v", err)
+	}
+	if sqlDB, err := gormDB.DB(); err == nil {
+		metrics.RegisterDBStats(sqlDB)
+	}
+	db := store.NewGorm(gormDB)
+	index := initializers.BuildSearchIndex(gormDB)
+	home := initializers.SetupTimeline(gormDB, cfg.Timeline.Strategy)
+	hub := initializers.ConnectEvents(cfg.Events, cfg.Database)
+	editing := initializers.SetupCollab(db, index, cfg.Collab.SaveInterval)
+	blobs := initializers.ConnectBlobStore(cfg.Storage)
+
+	images := workers.NewImageWorker(gormDB, blobs)
+	purger := workers.NewPurger(gormDB, blobs, index, home, cfg.Trash.Retention)
+	images.Start()
+	purger.Start()
+
+	srv := controllers.NewServer(cfg, controllers.Deps{
+		Store:    db,
+		Search:   index,
+		Blobs:    blobs,
+		Timeline: home,
+		Events:   hub,
+		Collab:   editing,
+		Images:   images,
+		Purger:   purger,
+	})
+	if err := srv.FailInterruptedImports(); err != nil {
+		log.Fatalf("Could not fail interrupted imports: %v", err)
+	}
+	auth := middleware.RequireAuth(db, cfg.Auth.Secret)
+
+	// Limits are keyed by account behind authentication and by client IP
+	// elsewhere. Health probes and metrics are never limited.
+	limiter := ratelimit.NewMemoryStore()
+	limit := func(name string, l config.Limit, keys ...middleware.KeyFunc) gin.HandlerFunc {
+		if !cfg.RateLimit.Enabled {
+			return func(c *gin.Context) { c.Next() }
+		}
+		return middleware.RateLimit(limiter, name, ratelimit.Limit(l), keys...)
+	}
+	loginLimit := limit("login", cfg.RateLimit.Login, middleware.ByIP)
+	signupLimit := limit("signup", cfg.RateLimit.Signup, middleware.ByIP)
+	feedLimit := limit("feeds", cfg.RateLimit.Reads, middleware.ByIP)
+	apiLimit := middleware.ReadWrite(
+		limit("reads", cfg.RateLimit.Reads, middleware.ByAccount),
+		limit("writes", cfg.RateLimit.Writes, middleware.ByAccount),
+	)
+
+	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
+	router.Use(middleware.Tracing(), middleware.RequestLogger(logger), middleware.Metrics(), middleware.Errors(), middleware.Recover(logger))
+	router.NoRoute(middleware.NotFound)
+	if cfg.Metrics.Enabled {
+		router.GET(cfg.Metrics.Path, gin.WrapH(metrics.Handler()))
+	}
+	// Health Probes
+	router.GET("healthz", srv.Healthz)
+	router.GET("readyz", srv.Readyz)
+
+	api := router.Group("", auth, apiLimit)
+
 	// Account Handlers
-	router.GET("account/", middleware.RequireAuth, controllers.AccountDetail)
-	router.POST("account/create/", controllers.AccountCreate)
-	router.POST("account/login/", controllers.AccountLogin)
-	router.PUT("account/", middleware.RequireAuth, controllers.AccountUpdate)
-	router.DELETE("account/", middleware.RequireAuth, controllers.AccountDelete)
+	api.GET("account/", srv.AccountDetail)
+	router.POST("account/create/", signupLimit, srv.AccountCreate)
+	router.POST("account/login/", loginLimit, srv.AccountLogin)
+	api.PUT("account/", srv.AccountUpdate)
+	api.DELETE("account/", srv.AccountDelete)
+	api.GET("account/export", srv.AccountExport)
+	api.POST("account/import", srv.AccountImport)
+	api.GET("account/imports/:id", srv.ImportGet)
+
+	// Follow Handlers
+	api.POST("accounts/:handle/follow", srv.AccountFollow)
+	api.DELETE("accounts/:handle/follow", srv.AccountUnfollow)
+	api.GET("accounts/:handle/followers", srv.AccountFollowers)
+	api.GET("accounts/:handle/following", srv.AccountFollowing)
+	api.GET("timeline/", srv.TimelineHome)
 
 	// Post Handlers
-	router.POST("posts/", middleware.RequireAuth, controllers.PostsCreate)
-	router.GET("posts/:id", middleware.RequireAuth, controllers.PostGet)
-	router.PUT("posts/:id", middleware.RequireAuth, controllers.PostUpdate)
-	router.GET("posts/", middleware.RequireAuth, controllers.PostList)
-	router.DELETE("posts/:id", middleware.RequireAuth, controllers.PostDelete)
+	api.POST("posts/", srv.PostsCreate)
+	api.GET("posts/:id", srv.PostGet)
+	api.PUT("posts/:id", srv.PostUpdate)
+	api.GET("posts/", srv.PostList)
+	api.DELETE("posts/:id", srv.PostDelete)
+	api.GET("search/", srv.PostSearch)
+	api.GET("authors/:handle/posts/:slug", srv.PostPermalink)
+
+	// Live Editing Handlers
+	api.GET("posts/:id/live", srv.PostLive)
+	api.GET("posts/:id/collaborators", srv.CollaboratorList)
+	api.PUT("posts/:id/collaborators/:handle", srv.CollaboratorAdd)
+	api.DELETE("posts/:id/collaborators/:handle", srv.CollaboratorRemove)
+
+	// Trash Handlers
+	api.GET("trash/posts", srv.TrashList)
+	api.POST("trash/posts/:id/restore", srv.TrashRestore)
+	api.DELETE("trash/posts/:id", srv.TrashPurge)
+
+	// Event Stream
+	api.GET("events/", srv.EventStream)
+
+	// Feed Handlers, public so feed readers need no login
+	router.GET("feed/:format", feedLimit, srv.SiteFeed)
+	router.GET("authors/:handle/feed/:format", feedLimit, srv.AuthorFeed)
+
+	// Attachment Handlers
+	api.POST("attachments/", srv.AttachmentUpload)
+	api.GET("attachments/", srv.AttachmentList)
+	api.GET("attachments/:id", srv.AttachmentGet)
+	api.DELETE("attachments/:id", srv.AttachmentDelete)
+
+	// Comment Handlers
+	api.POST("posts/:id/comments", srv.CommentCreate)
+	api.GET("posts/:id/comments", srv.CommentList)
+	api.PUT("posts/:id/comments/lock", srv.CommentLock)
+	api.PUT("comments/:id", srv.CommentUpdate)
+	api.DELETE("comments/:id", srv.CommentDelete)
+
+	// Reaction Handlers
+	api.PUT("posts/:id/reactions/:type", srv.ReactionAdd)
+	api.DELETE("posts/:id/reactions/:type", srv.ReactionRemove)
+
+	// Tag Handlers
+	api.GET("tags/", srv.TagList)
+	api.GET("tags/:slug/posts", srv.TagPosts)
+	api.PUT("tags/:slug", srv.TagRename)
+	api.POST("tags/:slug/merge", srv.TagMerge)
 
 	//Bank
-	router.Run() // listen and serve on 0.0.0.0:8080
+	api.GET("wallet/", srv.WalletGet)
+
+	httpServer, err := server.New(cfg.Server, router)
+	if err != nil {
+		log.Fatalf("Could not set up server: %v", err)
+	}
+	// Event streams and live editing sessions never finish on their own,
+	// so they are ended as soon as the drain starts.
+	httpServer.OnShutdown(func() {
+		hub.Disconnect()
+		editing.Close()
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	runErr := httpServer.Run(ctx)
+
+	// Stop in dependency order: nothing may touch the database once its
+	// pool is closed.
+	srv.Close()
+	images.Stop()
+	purger.Stop()
+	if err := hub.Close(); err != nil {
+		slog.Error("Failed to close event broker", "error", err)
+	}
+	if sqlDB, err := gormDB.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			slog.Error("Failed to close database", "error", err)
+		}
+	}
+	flushCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+	cancel()
+	if runErr != nil {
+		log.Fatalf("Server stopped: %v", runErr)
+	}
+	slog.Info("Shutdown complete")
 }
@@ -0,0 +1,128 @@
+// Package imaging produces resized, metadata-free variants of uploaded
+// images using only the standard library and golang.org/x/image.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	_ "image/gif"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// MaxPixels bounds the decoded size of an image to guard against
+// decompression bombs.
+const MaxPixels = 40_000_000
+
+const jpegQuality = 85
+
+// ErrTooLarge is returned for images above MaxPixels.
+var ErrTooLarge = errors.New("image dimensions too large")
+
+// Spec names a variant and the box its longest side must fit in.
+// A MaxSide of zero keeps the original dimensions.
+type Spec struct {
+	Name    string
+	MaxSide int
+}
+
+// Specs are the variants generated for every image.
+var Specs = []Spec{
+	{Name: "thumbnail", MaxSide: 200},
+	{Name: "medium", MaxSide: 800},
+	{Name: "original", MaxSide: 0},
+}
+
+// Variant is one encoded rendition of an image.
+type Variant struct {
+	Name     string
+	Data     []byte
+	Width    int
+	Height   int
+	MIMEType string
+}
+
+// Supported reports whether Generate can decode images of mimeType.
+func Supported(mimeType string) bool {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// Generate decodes an image and re-encodes it once per Spec. Encoding
+// from decoded pixels drops EXIF and every other metadata block; the EXIF
+// orientation of JPEGs is applied first so the output displays upright.
+// Images with transparency (PNG, GIF) become PNG, the rest JPEG.
+func Generate(r io.Reader) ([]Variant, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if format == "jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+
+	variants := make([]Variant, 0, len(Specs))
+	for _, spec := range Specs {
+		scaled := fit(img, spec.MaxSide)
+		var buf bytes.Buffer
+		mimeType := "image/jpeg"
+		if format == "png" || format == "gif" {
+			mimeType = "image/png"
+			err = png.Encode(&buf, scaled)
+		} else {
+			err = jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: jpegQuality})
+		}
+		if err != nil {
+			return nil, err
+		}
+		b := scaled.Bounds()
+		variants = append(variants, Variant{
+			Name:     spec.Name,
+			Data:     buf.Bytes(),
+			Width:    b.Dx(),
+			Height:   b.Dy(),
+			MIMEType: mimeType,
+		})
+	}
+	return variants, nil
+}
+
+// fit scales img down so its longest side is at most maxSide, keeping
+// the aspect ratio. Images are never scaled up.
+func fit(img image.Image, maxSide int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if maxSide == 0 || (w <= maxSide && h <= maxSide) {
+		return img
+	}
+	if w >= h {
+		h = max(1, h*maxSide/w)
+		w = maxSide
+	} else {
+		w = max(1, w*maxSide/h)
+		h = maxSide
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
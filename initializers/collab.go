@@ -0,0 +1,57 @@
+package initializers
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"genesis/collab"
+	"genesis/render"
+	"genesis/search"
+	"genesis/store"
+)
+
+// SetupCollab starts the live editing hub, writing live edits back to
+// the post every saveInterval.
+func SetupCollab(posts store.Store, index search.Searcher, saveInterval time.Duration) *collab.Hub {
+	return collab.NewHub(postDocuments{posts: posts, index: index}, saveInterval)
+}
+
+// postDocuments stores live editing sessions in the body of models.Post.
+type postDocuments struct {
+	posts store.Store
+	index search.Searcher
+}
+
+func (d postDocuments) Load(postID uint) (string, uint, error) {
+	post, err := d.posts.Posts().Get(postID)
+	return post.Body, post.Version, err
+}
+
+// Save updates the post in place, guarded by version like PostUpdate,
+// and refreshes the rendered body and the search index.
+func (d postDocuments) Save(postID uint, text string, version uint) (uint, error) {
+	post, err := d.posts.Posts().Get(postID)
+	if err != nil {
+		return 0, err
+	}
+	if post.Version != version {
+		return 0, collab.ErrConflict
+	}
+	rendered, err := render.HTML(post.Format, text)
+	if err != nil {
+		return 0, err
+	}
+	next, err := d.posts.Posts().UpdateBody(postID, version, text, rendered)
+	if errors.Is(err, store.ErrModified) {
+		return 0, collab.ErrConflict
+	}
+	if err != nil {
+		return 0, err
+	}
+	post.Body, post.BodyHTML = text, rendered
+	if err := d.index.Index(search.PostDocument(post)); err != nil {
+		slog.Error("Failed to index post", "post_id", postID, "error", err)
+	}
+	return next, nil
+}
@@ -2,20 +2,36 @@ package initializers
 
 import (
 	"log"
-	"os"
+	"log/slog"
+
+	"genesis/config"
+	"genesis/database"
+	"genesis/logging"
 
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-var DB *gorm.DB
-
-func ConnectDB() {
-	var err error
-	dsn := os.Getenv("DATABASE_STRING")
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
-
+// ConnectDB opens the configured database and tunes its pool. In-memory
+// SQLite, or database.auto_migrate, creates the schema on start.
+func ConnectDB(cfg config.Database) *gorm.DB {
+	dbCfg := database.Config{
+		Driver:          cfg.Driver,
+		DSN:             cfg.DSN,
+		MaxOpenConns:    cfg.MaxOpenConns,
+		MaxIdleConns:    cfg.MaxIdleConns,
+		ConnMaxLifetime: cfg.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.ConnMaxIdleTime,
+		Logger:          logging.NewGormLogger(slog.Default()),
+	}
+	db, err := database.Open(dbCfg)
 	if err != nil {
-		log.Fatal("Could not connect to Database")
+		log.Fatalf("Could not connect to Database: %v", err)
+	}
+
+	if dbCfg.Memory() || cfg.AutoMigrate {
+		if err := database.Migrate(db); err != nil {
+			log.Fatalf("Could not migrate Database: %v", err)
+		}
 	}
+	return db
 }
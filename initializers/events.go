@@ -0,0 +1,37 @@
+package initializers
+
+import (
+	"log"
+
+	"genesis/config"
+	"genesis/database"
+	"genesis/events"
+)
+
+// ConnectEvents sets up the event hub on the configured broker: "memory"
+// for a single instance, or "postgres" to share events between instances
+// over the database connection in db.
+func ConnectEvents(cfg config.Events, db config.Database) *events.Hub {
+	var broker events.Broker
+	switch cfg.Broker {
+	case "memory":
+		broker = events.NewMemoryBroker()
+	case "postgres":
+		if db.Driver != database.DriverPostgres {
+			log.Fatalf("The postgres event broker needs the postgres database driver, not %q", db.Driver)
+		}
+		pg, err := events.NewPostgresBroker(db.DSN)
+		if err != nil {
+			log.Fatalf("Could not connect event broker: %v", err)
+		}
+		broker = pg
+	default:
+		log.Fatalf("Unknown event broker %q", cfg.Broker)
+	}
+
+	hub, err := events.NewHub(broker, cfg.Replay)
+	if err != nil {
+		log.Fatalf("Could not subscribe to events: %v", err)
+	}
+	return hub
+}
@@ -0,0 +1,29 @@
+package initializers
+
+import (
+	"log"
+
+	"genesis/models"
+	"genesis/search"
+
+	"gorm.io/gorm"
+)
+
+// BuildSearchIndex creates the in-process search index and loads every
+// post of a live account into it. Handlers keep it in sync afterwards, but only for writes
+// made through this process, so the index assumes a single instance.
+func BuildSearchIndex(db *gorm.DB) search.Searcher {
+	index := search.NewMemoryIndex()
+	var posts []models.Post
+	live := db.Model(&models.Account{}).Select("id")
+	err := db.Where("account_id IN (?)", live).FindInBatches(&posts, 500, func(tx *gorm.DB, batch int) error {
+		for _, post := range posts {
+			index.Index(search.PostDocument(post))
+		}
+		return nil
+	}).Error
+	if err != nil {
+		log.Fatalf("Could not build search index: %v", err)
+	}
+	return index
+}
@@ -0,0 +1,36 @@
+package initializers
+
+import (
+	"context"
+	"log"
+
+	"genesis/config"
+	"genesis/storage"
+)
+
+// ConnectBlobStore opens the configured blob store: "local", rooted at
+// cfg.Dir, or "s3" for any S3-compatible endpoint.
+func ConnectBlobStore(cfg config.Storage) storage.BlobStore {
+	var blobs storage.BlobStore
+	var err error
+	switch cfg.Backend {
+	case "s3":
+		blobs, err = storage.NewS3Store(context.Background(), storage.S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			Region:    cfg.S3.Region,
+			Bucket:    cfg.S3.Bucket,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+			UseSSL:    cfg.S3.UseSSL,
+		})
+	case "local":
+		blobs, err = storage.NewLocalStore(cfg.Dir)
+	default:
+		log.Fatalf("Unknown blob store %q", cfg.Backend)
+	}
+
+	if err != nil {
+		log.Fatalf("Could not open blob store: %v", err)
+	}
+	return blobs
+}
@@ -0,0 +1,21 @@
+package initializers
+
+import (
+	"log"
+
+	"genesis/timeline"
+
+	"gorm.io/gorm"
+)
+
+// SetupTimeline picks the home timeline strategy: "read" or "write".
+func SetupTimeline(db *gorm.DB, strategy string) timeline.Strategy {
+	switch strategy {
+	case "read":
+		return timeline.NewReadStrategy(db)
+	case "write":
+		return timeline.NewWriteStrategy(db)
+	}
+	log.Fatalf("Unknown timeline strategy %q", strategy)
+	return nil
+}
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// slowQuery is how long a query may take before it is logged as a warning.
+const slowQuery = 200 * time.Millisecond
+
+// GormLogger writes GORM's logs to a slog.Logger: failed and slow
+// queries as warnings or errors, and every query at debug level.
+type GormLogger struct {
+	logger *slog.Logger
+}
+
+// NewGormLogger returns a GORM logger writing to logger.
+func NewGormLogger(logger *slog.Logger) GormLogger {
+	return GormLogger{logger: logger}
+}
+
+// LogMode is a no-op; the level is the slog logger's.
+func (l GormLogger) LogMode(gormlogger.LogLevel) gormlogger.Interface {
+	return l
+}
+
+func (l GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	l.logger.InfoContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	l.logger.WarnContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	l.logger.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+// Trace logs one query. Missing records are an expected outcome, not an
+// error.
+func (l GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		l.logger.ErrorContext(ctx, "Query failed", "sql", sql, "rows", rows, "elapsed", elapsed, "error", err)
+	case elapsed > slowQuery:
+		sql, rows := fc()
+		l.logger.WarnContext(ctx, "Slow query", "sql", sql, "rows", rows, "elapsed", elapsed)
+	case l.logger.Enabled(ctx, slog.LevelDebug):
+		sql, rows := fc()
+		l.logger.DebugContext(ctx, "Query", "sql", sql, "rows", rows, "elapsed", elapsed)
+	}
+}
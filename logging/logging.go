@@ -0,0 +1,79 @@
+// Package logging sets up structured logging with log/slog. Every line
+// carries the request and trace it was written for, when there is one,
+// and values that look like credentials are redacted before they are
+// written.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"genesis/config"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// New returns a logger writing to w in the configured format and level.
+func New(cfg config.Log, w io.Writer) *slog.Logger {
+	var level slog.Level
+	level.UnmarshalText([]byte(cfg.Level))
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+
+	var handler slog.Handler
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(contextHandler{handler})
+}
+
+// Request describes the HTTP request a log line was written for.
+// AccountID is filled in once the caller is authenticated.
+type Request struct {
+	ID        string
+	Method    string
+	Route     string
+	AccountID uint
+}
+
+type requestKey struct{}
+
+// WithRequest returns a context whose log lines carry req.
+func WithRequest(ctx context.Context, req *Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, req)
+}
+
+// RequestFrom returns the request ctx was created for, or nil.
+func RequestFrom(ctx context.Context) *Request {
+	req, _ := ctx.Value(requestKey{}).(*Request)
+	return req
+}
+
+// contextHandler adds the request and trace in the record's context to
+// the record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if req := RequestFrom(ctx); req != nil {
+		r.AddAttrs(slog.String("request_id", req.ID), slog.String("method", req.Method), slog.String("route", req.Route))
+		if req.AccountID != 0 {
+			r.AddAttrs(slog.Uint64("account_id", uint64(req.AccountID)))
+		}
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
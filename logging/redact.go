@@ -0,0 +1,40 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[redacted]"
+
+// sensitiveKeys are attribute names whose values are never written.
+var sensitiveKeys = []string{"password", "token", "secret", "cookie", "authorization", "dsn"}
+
+// sensitiveValues match credentials wherever they appear in a value, e.g.
+// in an error message or SQL statement: JWTs and bcrypt hashes.
+var sensitiveValues = regexp.MustCompile(`eyJ[\w-]+\.[\w-]+\.[\w-]+|\$2[aby]?\$\d\d\$[./A-Za-z0-9]{53}`)
+
+// redact is the ReplaceAttr hook of the handlers.
+func redact(_ []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return slog.String(a.Key, redacted)
+		}
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(redactString(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(redactString(err.Error()))
+		}
+	}
+	return a
+}
+
+func redactString(s string) string {
+	return sensitiveValues.ReplaceAllString(s, redacted)
+}
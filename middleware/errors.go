@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"genesis/problem"
+	"genesis/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Errors returns middleware that renders the last error a handler
+// reported with c.Error as problem+json, unless the handler already
+// answered. Errors that are not a *problem.Error are answered as internal
+// errors without their text. Place it before Recover, so panics are
+// rendered too.
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		p := problem.From(c.Errors.Last().Err)
+		c.Header("Content-Type", problem.ContentType)
+		c.JSON(p.Code.Status(), p.Details(c.Request.URL.Path, tracing.TraceID(c.Request.Context())))
+	}
+}
+
+// Abort stops the request with err, for Errors to render.
+func Abort(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
+
+// NotFound reports requests that match no route.
+func NotFound(c *gin.Context) {
+	c.Error(problem.New(problem.NotFound, "No such endpoint"))
+}
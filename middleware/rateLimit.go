@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"genesis/metrics"
+	"genesis/problem"
+	"genesis/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyFunc names the caller a request is counted against, or returns ""
+// when it cannot tell.
+type KeyFunc func(c *gin.Context) string
+
+// ByIP identifies callers by their IP address. Forwarding headers are
+// only believed from the router's trusted proxies.
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByAccount identifies callers by the account RequireAuth found, so it
+// must come after it.
+func ByAccount(c *gin.Context) string {
+	if id := c.GetUint("accountID"); id != 0 {
+		return "account:" + strconv.FormatUint(uint64(id), 10)
+	}
+	return ""
+}
+
+// RateLimit returns middleware that counts requests against limit under
+// name, keyed by the first of keys to identify the caller. Responses
+// carry the RateLimit headers, and requests over the limit are answered
+// 429 with Retry-After. When the store fails, requests are let through.
+func RateLimit(store ratelimit.Store, name string, limit ratelimit.Limit, keys ...KeyFunc) gin.HandlerFunc {
+	policy := strconv.Itoa(limit.Requests) + ";w=" + strconv.Itoa(int(limit.Per.Seconds()))
+	return func(c *gin.Context) {
+		var key string
+		for _, fn := range keys {
+			if key = fn(c); key != "" {
+				break
+			}
+		}
+		if key == "" {
+			key = ByIP(c)
+		}
+
+		result, err := store.Take(c.Request.Context(), name+":"+key, limit)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to check rate limit", "limit", name, "error", err)
+			c.Next()
+			return
+		}
+		c.Header("RateLimit-Policy", policy)
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", ceilSeconds(result.Reset))
+		if !result.Allowed {
+			metrics.RateLimited.WithLabelValues(name).Inc()
+			c.Header("Retry-After", ceilSeconds(result.RetryAfter))
+			Abort(c, problem.New(problem.RateLimited, "Too many requests, retry later"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// ReadWrite returns middleware that applies reads to safe methods and
+// writes to every other one.
+func ReadWrite(reads, writes gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			reads(c)
+		default:
+			writes(c)
+		}
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"genesis/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limit := ratelimit.Limit{Requests: 2, Per: time.Minute}
+	router := gin.New()
+	router.Use(Errors(), func(c *gin.Context) {
+		if c.GetHeader("X-Account") == "7" {
+			c.Set("accountID", uint(7))
+		}
+	})
+	router.GET("limited", RateLimit(ratelimit.NewMemoryStore(), "test", limit, ByAccount), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	get := func(account string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+		req.Header.Set("X-Account", account)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for i, remaining := range []string{"1", "0"} {
+		w := get("7")
+		if w.Code != http.StatusNoContent || w.Header().Get("RateLimit-Remaining") != remaining {
+			t.Fatalf("request %d = %d with %s remaining, want 204 with %s", i+1, w.Code, w.Header().Get("RateLimit-Remaining"), remaining)
+		}
+	}
+	w := get("7")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" {
+		t.Fatalf("request over the limit = %d, Retry-After %q; want 429 after 30s", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	// Anonymous callers fall back to their IP and get a bucket of their own
+	if w := get(""); w.Code != http.StatusNoContent {
+		t.Fatalf("anonymous request = %d, want 204", w.Code)
+	}
+}
+
+func TestMemoryStoreRefill(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{Requests: 1, Per: 50 * time.Millisecond}
+	ctx := t.Context()
+	if r, _ := store.Take(ctx, "k", limit); !r.Allowed {
+		t.Fatal("first request refused")
+	}
+	if r, _ := store.Take(ctx, "k", limit); r.Allowed {
+		t.Fatal("second request allowed before the bucket refilled")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if r, _ := store.Take(ctx, "k", limit); !r.Allowed {
+		t.Fatal("request refused after the bucket refilled")
+	}
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"genesis/logging"
+	"genesis/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID limits the IDs accepted from clients and proxies, so
+// they are safe to echo and log.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestLogger returns middleware that gives every request an ID, taken
+// from X-Request-ID when the client sent a usable one, and logs the
+// request once it is answered. Log lines written with the request's
+// context carry the same ID.
+func RequestLogger(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+
+		req := &logging.Request{ID: id, Method: c.Request.Method, Route: c.FullPath()}
+		c.Request = c.Request.WithContext(logging.WithRequest(c.Request.Context(), req))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		attrs := []slog.Attr{
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", c.Errors.String()))
+		}
+		logger.LogAttrs(c.Request.Context(), level, "Request", attrs...)
+	}
+}
+
+// Recover returns middleware that logs a panicking handler with its stack
+// and reports an internal error instead of dropping the connection.
+func Recover(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				if r == http.ErrAbortHandler {
+					panic(r)
+				}
+				logger.ErrorContext(c.Request.Context(), "Handler panicked", "panic", r, "stack", string(debug.Stack()))
+				Abort(c, problem.New(problem.Internal, "Something unexpected happened"))
+			}
+		}()
+		c.Next()
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -1,26 +1,34 @@
 package middleware
 
 import (
-	"genesis/initializers"
-	"genesis/models"
-	"log"
-	"net/http"
-	"os"
+	"log/slog"
 	"time"
 
+	"genesis/logging"
+	"genesis/problem"
+	"genesis/store"
+
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func RequireAuth(c *gin.Context) {
+// RequireAuth returns middleware that admits requests carrying a valid
+// token for an existing account, signed with secret, and stores its ID as
+// "accountID".
+func RequireAuth(db store.Store, secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireAuth(c, db.WithContext(c.Request.Context()).Accounts(), secret)
+	}
+}
+
+func requireAuth(c *gin.Context, accounts store.AccountStore, secret string) {
 	// Get cookie off req
 	tokenString, err := c.Cookie("Authorization")
 	if err != nil {
-		log.Println("No token found in cookie")
-		c.AbortWithStatus(http.StatusUnauthorized)
+		slog.DebugContext(c.Request.Context(), "No token found in cookie")
+		Abort(c, problem.New(problem.Unauthenticated, "Log in to use this endpoint"))
 		return
 	}
-	log.Println(tokenString)
 	// Decode/Validate token within cookie
 
 	// Parse takes the token string and a function for looking up the key. The latter is especially
@@ -29,34 +37,37 @@ func RequireAuth(c *gin.Context) {
 	// to the callback, providing flexibility.
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		// hmacSampleSecret is a []byte containing your secret, e.g. []byte("my_secret_key")
-		return []byte(os.Getenv("SECRET")), nil
+		return []byte(secret), nil
 	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 	if err != nil {
-		log.Fatal(err)
-		c.AbortWithStatus(http.StatusUnauthorized)
+		slog.InfoContext(c.Request.Context(), "Rejected token", "error", err)
+		Abort(c, problem.New(problem.Unauthenticated, "The login token is invalid"))
 		return
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok {
 		// Check Expiration
 		if float64(time.Now().Unix()) > claims["exp"].(float64) {
-			c.AbortWithStatus(http.StatusUnauthorized)
+			Abort(c, problem.New(problem.Unauthenticated, "The login token has expired"))
 			return
 		}
 		// Find Account attached to token sub
-		var existingAccount models.Account
-		initializers.DB.First(&existingAccount, claims["sub"])
-		if existingAccount.ID == 0 {
-			log.Println("Account Does not exist")
-			c.AbortWithStatus(http.StatusUnauthorized)
+		sub, _ := claims["sub"].(float64)
+		existingAccount, err := accounts.Get(uint(sub))
+		if err != nil {
+			slog.InfoContext(c.Request.Context(), "Account Does not exist", "account", uint(sub))
+			Abort(c, problem.New(problem.Unauthenticated, "The account of the login token no longer exists"))
 			return
 		}
 		// Attach to req
 		c.Set("accountID", existingAccount.ID)
+		if req := logging.RequestFrom(c.Request.Context()); req != nil {
+			req.AccountID = existingAccount.ID
+		}
 		// Continue
 		c.Next()
 	} else {
-		c.AbortWithStatus(http.StatusUnauthorized)
+		Abort(c, problem.New(problem.Unauthenticated, "The login token is invalid"))
 	}
 
 }
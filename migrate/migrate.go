@@ -1,16 +1,117 @@
 package main
 
 import (
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+
+	"genesis/config"
+	"genesis/database"
 	"genesis/initializers"
+	"genesis/logging"
 	"genesis/models"
+	"genesis/permalink"
+	"genesis/render"
+
+	"gorm.io/gorm"
 )
 
-func init() {
-	initializers.LoadEnvVariables()
-	initializers.ConnectDB()
+func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	slog.SetDefault(logging.New(cfg.Log, os.Stderr))
+	slog.SetLogLoggerLevel(slog.LevelError)
+	db := initializers.ConnectDB(cfg.Database)
+
+	if err := backfillPermalinks(db); err != nil {
+		log.Fatalf("Failed to backfill permalinks: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		log.Fatalf("Failed to migrate: %v", err)
+	}
+
+	if err := renderPostBodies(db); err != nil {
+		log.Fatalf("Failed to render post bodies: %v", err)
+	}
 }
 
-func main() {
-	initializers.DB.AutoMigrate(&models.Post{})
-	initializers.DB.AutoMigrate(&models.Account{})
+// renderPostBodies fills the cached HTML of posts written before bodies
+// were rendered. Posts that fail to render are logged and left empty.
+func renderPostBodies(db *gorm.DB) error {
+	var posts []models.Post
+	return db.Where("body_html = '' OR body_html IS NULL").FindInBatches(&posts, 200, func(tx *gorm.DB, batch int) error {
+		for _, post := range posts {
+			rendered, err := render.HTML(post.Format, post.Body)
+			if err != nil {
+				slog.Error("Failed to render post", "post_id", post.ID, "error", err)
+				continue
+			}
+			if err := db.Model(&post).UpdateColumn("body_html", rendered).Error; err != nil {
+				return fmt.Errorf("saving rendered body of post %d: %w", post.ID, err)
+			}
+		}
+		return nil
+	}).Error
+}
+
+// backfillPermalinks gives existing accounts a handle and existing posts a
+// slug. It runs before AutoMigrate so the unique indexes are created over
+// filled columns. Each column is added and filled in one transaction, so
+// a failed backfill leaves the column missing and runs again next time.
+func backfillPermalinks(db *gorm.DB) error {
+	if db.Migrator().HasTable(&models.Account{}) && !db.Migrator().HasColumn(&models.Account{}, "Handle") {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&models.Account{}, "Handle"); err != nil {
+				return err
+			}
+			var accounts []models.Account
+			if err := tx.Unscoped().Select("id, email").Order("id").Find(&accounts).Error; err != nil {
+				return err
+			}
+			for _, account := range accounts {
+				handle, err := permalink.Handle(tx, account.Email)
+				if err != nil {
+					return fmt.Errorf("deriving handle for account %d: %w", account.ID, err)
+				}
+				if err := tx.Unscoped().Model(&account).UpdateColumn("handle", handle).Error; err != nil {
+					return fmt.Errorf("saving handle for account %d: %w", account.ID, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("account handles: %w", err)
+		}
+	}
+	if db.Migrator().HasTable(&models.Post{}) && !db.Migrator().HasColumn(&models.Post{}, "Slug") {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&models.Post{}, "Slug"); err != nil {
+				return err
+			}
+			if err := tx.AutoMigrate(&models.PostSlugRedirect{}); err != nil {
+				return err
+			}
+			var posts []models.Post
+			if err := tx.Unscoped().Select("id, account_id, title").Order("id").Find(&posts).Error; err != nil {
+				return err
+			}
+			for _, post := range posts {
+				slug, err := permalink.PostSlug(tx, post.AccountID, post.ID, post.Title)
+				if err != nil {
+					return fmt.Errorf("deriving slug for post %d: %w", post.ID, err)
+				}
+				if err := tx.Unscoped().Model(&post).UpdateColumn("slug", slug).Error; err != nil {
+					return fmt.Errorf("saving slug for post %d: %w", post.ID, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("post slugs: %w", err)
+		}
+	}
+	return nil
 }
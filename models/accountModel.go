@@ -4,8 +4,18 @@ import "gorm.io/gorm"
 
 type Account struct {
 	gorm.Model
-	Email    string `gorm:"unique"`
-	Password string
-	Posts    []Post `gorm:"foreignKey:AccountID;constraint:OnDelete:CASCADE"`
-	Wallet   Wallet
+	Email       string `gorm:"type:varchar(255);unique"`
+	Handle      string `gorm:"type:varchar(32);uniqueIndex"` // public name used in permalinks
+	Password    string
+	Posts       []Post             `gorm:"foreignKey:AccountID;constraint:OnDelete:CASCADE"`
+	Comments    []Comment          `gorm:"foreignKey:AccountID;constraint:OnDelete:CASCADE"`
+	Reactions   []Reaction         `gorm:"foreignKey:AccountID;constraint:OnDelete:CASCADE"`
+	Tags        []Tag              `gorm:"foreignKey:AccountID;constraint:OnDelete:CASCADE"`
+	Attachments []Attachment       `gorm:"foreignKey:AccountID;constraint:OnDelete:CASCADE"`
+	Following   []Follow           `gorm:"foreignKey:FollowerID;constraint:OnDelete:CASCADE"`
+	Followers   []Follow           `gorm:"foreignKey:FolloweeID;constraint:OnDelete:CASCADE"`
+	Timeline    []TimelineEntry    `gorm:"foreignKey:AccountID;constraint:OnDelete:CASCADE"`
+	Imports     []ImportJob        `gorm:"foreignKey:AccountID;constraint:OnDelete:CASCADE"`
+	Coauthoring []PostCollaborator `gorm:"foreignKey:AccountID;constraint:OnDelete:CASCADE"`
+	Wallet      Wallet             `gorm:"constraint:OnDelete:SET NULL"`
 }
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Attachment is a file an account uploaded. Contents live in the blob
+// store under Hash, so identical uploads share one blob. An account holds
+// at most one attachment per hash.
+type Attachment struct {
+	ID        uint   `gorm:"primarykey"`
+	AccountID uint   `gorm:"not null;uniqueIndex:idx_attachment_account_hash,priority:1"`
+	Hash      string `gorm:"type:varchar(64);not null;index;uniqueIndex:idx_attachment_account_hash,priority:2"`
+	Size      int64  `gorm:"not null"`
+	MIMEType  string `gorm:"type:varchar(255);not null"`
+	Filename  string
+	// VariantStatus tracks image variant generation: empty for non-images,
+	// then "pending", "ready" or "failed".
+	VariantStatus string              `gorm:"type:varchar(16);index"`
+	Variants      []AttachmentVariant `gorm:"foreignKey:AttachmentID;constraint:OnDelete:CASCADE"`
+	Posts         []Post              `gorm:"many2many:post_attachments;constraint:OnDelete:CASCADE"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// AttachmentVariant is a resized, metadata-free rendition of an image
+// attachment, stored in the blob store under its own content hash.
+type AttachmentVariant struct {
+	ID           uint   `gorm:"primarykey"`
+	AttachmentID uint   `gorm:"not null;uniqueIndex:idx_variant_attachment_name,priority:1"`
+	Name         string `gorm:"type:varchar(32);not null;uniqueIndex:idx_variant_attachment_name,priority:2"`
+	Hash         string `gorm:"type:varchar(64);not null;index"`
+	Size         int64  `gorm:"not null"`
+	MIMEType     string `gorm:"type:varchar(255);not null"`
+	Width        int
+	Height       int
+	CreatedAt    time.Time
+}
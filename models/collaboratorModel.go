@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// PostCollaborator grants an account the right to edit another author's
+// post in live editing sessions.
+type PostCollaborator struct {
+	PostID    uint `gorm:"primaryKey"`
+	AccountID uint `gorm:"primaryKey;index"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,14 @@
+package models
+
+import "gorm.io/gorm"
+
+// Comment is a reply to a post. Top-level comments have no ParentID;
+// replies point at the comment they answer.
+type Comment struct {
+	gorm.Model
+	Body      string
+	PostID    uint      `gorm:"not null;index"`
+	AccountID uint      `gorm:"not null;index"`
+	ParentID  *uint     `gorm:"index"`
+	Replies   []Comment `gorm:"foreignKey:ParentID;constraint:OnDelete:CASCADE"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Follow records that FollowerID follows FolloweeID.
+type Follow struct {
+	FollowerID uint `gorm:"primaryKey"`
+	FolloweeID uint `gorm:"primaryKey;index"`
+	CreatedAt  time.Time
+}
+
+// TimelineEntry is a post materialized into a follower's home timeline by
+// the fan-out-on-write strategy. CreatedAt mirrors the post's.
+type TimelineEntry struct {
+	AccountID uint      `gorm:"primaryKey;index:idx_timeline_account_created,priority:1"`
+	PostID    uint      `gorm:"primaryKey;index"`
+	AuthorID  uint      `gorm:"not null;index"`
+	CreatedAt time.Time `gorm:"index:idx_timeline_account_created,priority:2"`
+}
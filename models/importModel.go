@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// ImportJob tracks a background import of a post archive.
+type ImportJob struct {
+	ID        uint   `gorm:"primarykey"`
+	AccountID uint   `gorm:"not null;index"`
+	Format    string `gorm:"type:varchar(16);not null"` // "markdown" or "jsonl"
+	Status    string `gorm:"type:varchar(16);not null"` // "pending", "running", "done" or "failed"
+	Total     int
+	Imported  int
+	Failed    int
+	Errors    []ImportError `gorm:"serializer:json"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ImportError explains why one archive item was not imported.
+type ImportError struct {
+	Item  string `json:"item"`
+	Error string `json:"error"`
+}
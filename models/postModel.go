@@ -4,7 +4,21 @@ import "gorm.io/gorm"
 
 type Post struct {
 	gorm.Model
-	Title     string
-	Body      string
-	AccountID uint
+	Title          string
+	Slug           string `gorm:"type:varchar(100);uniqueIndex:idx_post_account_slug,priority:2"` // unique per author
+	Body           string
+	Format         string             `gorm:"type:varchar(16);not null;default:plain"` // "plain" or "markdown"
+	BodyHTML       string             // sanitized rendering of Body, refreshed on every write
+	AccountID      uint               `gorm:"uniqueIndex:idx_post_account_slug,priority:1"`
+	Draft          bool               `gorm:"not null;default:false"` // only visible to the author
+	Version        uint               `gorm:"not null;default:1"`     // bumped on every write, backs the ETag
+	CommentsLocked bool               // stops new comments and comment edits
+	Comments       []Comment          `gorm:"foreignKey:PostID;constraint:OnDelete:CASCADE"`
+	Reactions      []Reaction         `gorm:"foreignKey:PostID;constraint:OnDelete:CASCADE"`
+	ReactionCounts []ReactionCount    `gorm:"foreignKey:PostID;constraint:OnDelete:CASCADE"`
+	Tags           []Tag              `gorm:"many2many:post_tags;constraint:OnDelete:CASCADE"`
+	Attachments    []Attachment       `gorm:"many2many:post_attachments;constraint:OnDelete:CASCADE"`
+	SlugRedirects  []PostSlugRedirect `gorm:"foreignKey:PostID;constraint:OnDelete:CASCADE"`
+	TimelineItems  []TimelineEntry    `gorm:"foreignKey:PostID;constraint:OnDelete:CASCADE"`
+	Collaborators  []PostCollaborator `gorm:"foreignKey:PostID;constraint:OnDelete:CASCADE"`
 }
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Reaction records that an account reacted to a post with a given type.
+// The composite key allows at most one reaction of each type per account
+// and post. Rows are hard deleted so the key can be reused.
+type Reaction struct {
+	PostID    uint   `gorm:"primaryKey"`
+	AccountID uint   `gorm:"primaryKey;index"`
+	Type      string `gorm:"primaryKey;type:varchar(32)"`
+	CreatedAt time.Time
+}
+
+// ReactionCount holds the running total of one reaction type on a post so
+// reads don't need to aggregate the reactions table.
+type ReactionCount struct {
+	PostID uint   `gorm:"primaryKey"`
+	Type   string `gorm:"primaryKey;type:varchar(32)"`
+	Count  int64  `gorm:"not null;default:0"`
+}
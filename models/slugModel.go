@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// PostSlugRedirect remembers a slug a post used before its title changed,
+// so links to the old permalink keep resolving.
+type PostSlugRedirect struct {
+	ID        uint   `gorm:"primarykey"`
+	AccountID uint   `gorm:"not null;uniqueIndex:idx_redirect_account_slug,priority:1"`
+	Slug      string `gorm:"type:varchar(100);not null;uniqueIndex:idx_redirect_account_slug,priority:2"`
+	PostID    uint   `gorm:"not null;index"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Tag is a lowercase slug an account uses to group its posts. Slugs are
+// unique per account, so renames and merges never touch other accounts.
+type Tag struct {
+	ID        uint   `gorm:"primarykey"`
+	AccountID uint   `gorm:"not null;uniqueIndex:idx_tag_account_slug,priority:1"`
+	Slug      string `gorm:"type:varchar(64);not null;uniqueIndex:idx_tag_account_slug,priority:2"`
+	Posts     []Post `gorm:"many2many:post_tags;constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -4,15 +4,17 @@ import (
 	"gorm.io/gorm"
 )
 
-// Account represents the accounts table (equivalent to Wallet)
+// Account represents the accounts table (equivalent to Wallet). Wallets
+// of purged accounts are kept as tombstones without an AccountID, since
+// the transfers of other accounts point at them.
 type Wallet struct {
 	gorm.Model
-	AccountID         uint
+	AccountID         *uint
 	Balance           int64      `gorm:"not null"`
-	Currency          string     `gorm:"type:varchar;not null"`
+	Currency          string     `gorm:"type:varchar(3);not null"`
 	Entries           []Entry    `gorm:"foreignKey:AccountID;constraint:OnDelete:CASCADE"`
-	SentTransfers     []Transfer `gorm:"foreignKey:FromAccountID;constraint:OnDelete:CASCADE"`
-	ReceivedTransfers []Transfer `gorm:"foreignKey:ToAccountID;constraint:OnDelete:CASCADE"`
+	SentTransfers     []Transfer `gorm:"foreignKey:FromAccountID;constraint:OnDelete:RESTRICT"`
+	ReceivedTransfers []Transfer `gorm:"foreignKey:ToAccountID;constraint:OnDelete:RESTRICT"`
 }
 
 // Entry represents the entries table
@@ -0,0 +1,135 @@
+// Package permalink generates the human-readable names used in post
+// URLs: account handles and per-author post slugs.
+package permalink
+
+import (
+	"fmt"
+	"strings"
+
+	"genesis/models"
+
+	"github.com/gosimple/unidecode"
+	"gorm.io/gorm"
+)
+
+// MaxSlugLength bounds generated slugs, leaving room for a -N suffix.
+const MaxSlugLength = 80
+
+// Slugify transliterates s to ASCII, lowercases it and joins the runs of
+// letters and digits with dashes: "Crème Brûlée!" becomes "creme-brulee".
+func Slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(unidecode.Unidecode(s)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if b.Len() > 0 && !dash {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	slug := strings.TrimSuffix(b.String(), "-")
+	if len(slug) > MaxSlugLength {
+		slug = slug[:MaxSlugLength]
+		if i := strings.LastIndexByte(slug, '-'); i > MaxSlugLength/2 {
+			slug = slug[:i]
+		}
+		slug = strings.TrimSuffix(slug, "-")
+	}
+	return slug
+}
+
+// HasBase reports whether slug is base itself or base with a -N suffix,
+// i.e. whether a title producing base may keep slug.
+func HasBase(slug, base string) bool {
+	if slug == base {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(slug, base+"-")
+	if !ok || suffix == "" {
+		return false
+	}
+	for _, r := range suffix {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Base is the slug a title produces before any suffix is added.
+func Base(title string) string {
+	if base := Slugify(title); base != "" {
+		return base
+	}
+	return "post"
+}
+
+// PostSlug returns a slug for title that is unique among the author's
+// posts, including deleted ones and slugs kept as redirects for other
+// posts, so old links never start pointing somewhere new.
+func PostSlug(tx *gorm.DB, accountID, postID uint, title string) (string, error) {
+	base := Base(title)
+	var used []string
+	if err := tx.Unscoped().Model(&models.Post{}).
+		Where("account_id = ? AND id <> ? AND (slug = ? OR slug LIKE ?)", accountID, postID, base, base+"-%").
+		Pluck("slug", &used).Error; err != nil {
+		return "", err
+	}
+	var redirected []string
+	if err := tx.Model(&models.PostSlugRedirect{}).
+		Where("account_id = ? AND post_id <> ? AND (slug = ? OR slug LIKE ?)", accountID, postID, base, base+"-%").
+		Pluck("slug", &redirected).Error; err != nil {
+		return "", err
+	}
+	return firstFree(base, append(used, redirected...)), nil
+}
+
+// Handle returns a handle derived from an email's local part that no
+// other account uses.
+func Handle(tx *gorm.DB, email string) (string, error) {
+	local, _, _ := strings.Cut(email, "@")
+	base := strings.ReplaceAll(Slugify(local), "-", "")
+	if len(base) < 3 {
+		base = "user" + base
+	}
+	if len(base) > 28 {
+		base = base[:28]
+	}
+	var used []string
+	if err := tx.Unscoped().Model(&models.Account{}).
+		Where("handle = ? OR handle LIKE ?", base, base+"%").
+		Pluck("handle", &used).Error; err != nil {
+		return "", err
+	}
+	taken := toSet(used)
+	if !taken[base] {
+		return base, nil
+	}
+	for n := 2; ; n++ {
+		if candidate := fmt.Sprintf("%s%d", base, n); !taken[candidate] {
+			return candidate, nil
+		}
+	}
+}
+
+func firstFree(base string, used []string) string {
+	taken := toSet(used)
+	if !taken[base] {
+		return base
+	}
+	for n := 2; ; n++ {
+		if candidate := fmt.Sprintf("%s-%d", base, n); !taken[candidate] {
+			return candidate
+		}
+	}
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
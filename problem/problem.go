@@ -0,0 +1,142 @@
+// Package problem describes API errors as RFC 7807 problem details.
+// Handlers report an *Error with a stable Code; the Errors middleware
+// renders it as application/problem+json with the status of its code.
+package problem
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ContentType is the media type of rendered problems.
+const ContentType = "application/problem+json"
+
+// Code identifies a kind of error to clients, which may branch on it.
+// Codes are stable: the detail text of an error may change, its code
+// does not.
+type Code string
+
+// Generic codes, one for each status the API answers with.
+const (
+	BadRequest           Code = "bad_request"
+	ValidationFailed     Code = "validation_failed"
+	Unauthenticated      Code = "unauthenticated"
+	Forbidden            Code = "forbidden"
+	NotFound             Code = "not_found"
+	Conflict             Code = "conflict"
+	PreconditionFailed   Code = "precondition_failed"
+	PayloadTooLarge      Code = "payload_too_large"
+	UnsupportedMediaType Code = "unsupported_media_type"
+	RateLimited          Code = "rate_limited"
+	Internal             Code = "internal_error"
+	Unavailable          Code = "unavailable"
+)
+
+// Codes for errors clients are expected to handle specifically.
+const (
+	InvalidCredentials Code = "invalid_credentials"
+	EmailTaken         Code = "email_taken"
+	HandleTaken        Code = "handle_taken"
+	CommentsLocked     Code = "comments_locked"
+	QuotaExceeded      Code = "quota_exceeded"
+)
+
+// statuses maps every code to the HTTP status it is answered with.
+var statuses = map[Code]int{
+	BadRequest:           http.StatusBadRequest,
+	ValidationFailed:     http.StatusUnprocessableEntity,
+	Unauthenticated:      http.StatusUnauthorized,
+	Forbidden:            http.StatusForbidden,
+	NotFound:             http.StatusNotFound,
+	Conflict:             http.StatusConflict,
+	PreconditionFailed:   http.StatusPreconditionFailed,
+	PayloadTooLarge:      http.StatusRequestEntityTooLarge,
+	UnsupportedMediaType: http.StatusUnsupportedMediaType,
+	RateLimited:          http.StatusTooManyRequests,
+	Internal:             http.StatusInternalServerError,
+	Unavailable:          http.StatusServiceUnavailable,
+
+	InvalidCredentials: http.StatusUnauthorized,
+	EmailTaken:         http.StatusConflict,
+	HandleTaken:        http.StatusConflict,
+	CommentsLocked:     http.StatusForbidden,
+	QuotaExceeded:      http.StatusForbidden,
+}
+
+// Status is the HTTP status of code; unknown codes are server errors.
+func (code Code) Status() int {
+	if status, ok := statuses[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Error is an error to report to the client. Err, when set, is the
+// underlying cause; it is logged but never shown to the client.
+type Error struct {
+	Code   Code
+	Detail string
+	Fields []FieldError
+	Err    error
+}
+
+// New returns an error with code, explained to the client by detail.
+func New(code Code, detail string) *Error {
+	return &Error{Code: code, Detail: detail}
+}
+
+// Wrap returns an error with code and detail caused by err.
+func Wrap(err error, code Code, detail string) *Error {
+	return &Error{Code: code, Detail: detail, Err: err}
+}
+
+func (e *Error) Error() string {
+	msg := string(e.Code) + ": " + e.Detail
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// From returns err as an *Error, treating anything else as an internal
+// error so its text is not shown to the client.
+func From(err error) *Error {
+	var p *Error
+	if errors.As(err, &p) {
+		return p
+	}
+	return Wrap(err, Internal, "Something unexpected happened")
+}
+
+// Details is the problem+json body of an error. Code, TraceID and Errors
+// are extension members.
+type Details struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     Code         `json:"code"`
+	TraceID  string       `json:"trace_id,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// Details describes e for the client. Codes carry the meaning, so the
+// type is about:blank and the title the status text.
+func (e *Error) Details(instance, traceID string) Details {
+	status := e.Code.Status()
+	return Details{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   e.Detail,
+		Instance: instance,
+		Code:     e.Code,
+		TraceID:  traceID,
+		Errors:   e.Fields,
+	}
+}
@@ -0,0 +1,109 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError explains why one field of a request was rejected.
+type FieldError struct {
+	// Field is the path of the field in the request body, e.g. tags[2].
+	Field string `json:"field"`
+	// Rule is the validation rule that failed, e.g. max.
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// Invalid returns the error for a request body that could not be bound.
+// Failed validation rules are listed field by field; a body that is not
+// JSON of the right shape is a bad request.
+func Invalid(err error) *Error {
+	var fields validator.ValidationErrors
+	if errors.As(err, &fields) {
+		e := Wrap(err, ValidationFailed, "The request body has invalid fields")
+		for _, field := range fields {
+			e.Fields = append(e.Fields, FieldError{
+				Field:   fieldPath(field),
+				Rule:    field.Tag(),
+				Param:   field.Param(),
+				Message: message(field),
+			})
+		}
+		return e
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		e := Wrap(err, ValidationFailed, "The request body has invalid fields")
+		e.Fields = []FieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Param:   typeErr.Type.String(),
+			Message: "must be of type " + typeErr.Type.String(),
+		}}
+		return e
+	}
+	return Wrap(err, BadRequest, "The request body is not valid JSON")
+}
+
+// JSONFieldNames makes v name fields by their JSON keys, as clients know
+// them.
+func JSONFieldNames(v *validator.Validate) {
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+}
+
+// fieldPath drops the struct name the namespace starts with.
+func fieldPath(field validator.FieldError) string {
+	_, path, ok := strings.Cut(field.Namespace(), ".")
+	if !ok {
+		return field.Field()
+	}
+	return path
+}
+
+// message words a failed validation rule.
+func message(field validator.FieldError) string {
+	param := field.Param()
+	switch field.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "alpha":
+		return "must contain only letters"
+	case "alphanum":
+		return "must contain only letters and digits"
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(param), ", ")
+	case "len":
+		return "must be exactly " + param + unit(field)
+	case "min":
+		return "must be at least " + param + unit(field)
+	case "max":
+		return "must be at most " + param + unit(field)
+	case "gt":
+		return "must be greater than " + param
+	}
+	return "fails " + field.Tag()
+}
+
+// unit names what a length rule counts for the field's kind.
+func unit(field validator.FieldError) string {
+	switch field.Kind() {
+	case reflect.String:
+		return " characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return " entries"
+	}
+	return ""
+}
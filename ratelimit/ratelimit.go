@@ -0,0 +1,40 @@
+// Package ratelimit counts requests against token buckets. Buckets live
+// in a Store, so instances can share them; MemoryStore keeps them in the
+// process.
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Limit allows bursts of Requests, refilled at Requests per Per.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// rate is how many tokens the bucket regains each second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// Result is the state of a bucket after a request was counted.
+type Result struct {
+	Allowed bool
+	// Limit is the size of the bucket and Remaining the requests left in it.
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request is allowed, when
+	// this one was not.
+	RetryAfter time.Duration
+}
+
+// Store keeps token buckets by key.
+type Store interface {
+	// Take counts a request against the bucket for key, creating a full
+	// bucket with limit when there is none.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
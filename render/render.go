@@ -0,0 +1,91 @@
+// Package render turns stored post bodies into sanitized HTML and plain text.
+package render
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Supported body formats.
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+)
+
+var (
+	// Raw HTML in markdown is dropped by goldmark; the policy is a second
+	// line of defence. Code highlighting uses chroma CSS classes rather
+	// than inline styles, so only class attributes need to be allowed.
+	policy = func() *bluemonday.Policy {
+		p := bluemonday.UGCPolicy()
+		p.AllowAttrs("class").Matching(regexp.MustCompile(`^[a-zA-Z0-9 _-]+$`)).OnElements("pre", "code", "span", "div")
+		p.RequireNoFollowOnLinks(true)
+		p.AddTargetBlankToFullyQualifiedLinks(true)
+		return p
+	}()
+	strip = bluemonday.StrictPolicy()
+
+	markdown = goldmark.New(
+		goldmark.WithExtensions(
+			extension.GFM,
+			highlighting.NewHighlighting(
+				highlighting.WithStyle("github"),
+				highlighting.WithFormatOptions(chromahtml.WithClasses(true)),
+			),
+		),
+	)
+
+	whitespace = regexp.MustCompile(`\s+`)
+)
+
+// HTML renders body in the given format to sanitized HTML. Plain bodies
+// are escaped and split into paragraphs on blank lines.
+func HTML(format, body string) (string, error) {
+	if format != FormatMarkdown {
+		var b strings.Builder
+		for _, para := range strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n\n") {
+			if para = strings.TrimSpace(para); para != "" {
+				b.WriteString("<p>" + strings.ReplaceAll(html.EscapeString(para), "\n", "<br>") + "</p>\n")
+			}
+		}
+		return b.String(), nil
+	}
+
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(body), &buf); err != nil {
+		return "", err
+	}
+	return policy.Sanitize(buf.String()), nil
+}
+
+// PlainText strips all markup from rendered HTML and collapses whitespace.
+func PlainText(rendered string) string {
+	text := html.UnescapeString(strip.Sanitize(rendered))
+	return strings.TrimSpace(whitespace.ReplaceAllString(text, " "))
+}
+
+// Excerpt returns at most limit characters of the plain text of rendered
+// HTML, cut at a word boundary where possible.
+func Excerpt(rendered string, limit int) string {
+	text := PlainText(rendered)
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	cut := []rune(text)[:limit]
+	for i := len(cut) - 1; i > limit/2; i-- {
+		if cut[i] == ' ' {
+			cut = cut[:i]
+			break
+		}
+	}
+	return strings.TrimRight(string(cut), " .,;:") + "…"
+}
@@ -0,0 +1,106 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    string
+		body      string
+		forbidden []string
+		want      []string
+	}{
+		{
+			name:      "script in markdown",
+			format:    FormatMarkdown,
+			body:      "hello <script>alert(1)</script>",
+			forbidden: []string{"<script", "alert(1)</script>"},
+			want:      []string{"hello"},
+		},
+		{
+			name:      "javascript link in markdown",
+			format:    FormatMarkdown,
+			body:      "[click](javascript:alert(1))",
+			forbidden: []string{"javascript:"},
+			want:      []string{"click"},
+		},
+		{
+			name:      "event handler in markdown",
+			format:    FormatMarkdown,
+			body:      `<img src="x.png" onerror="alert(1)">`,
+			forbidden: []string{"onerror"},
+		},
+		{
+			name:      "script in plain text",
+			format:    FormatPlain,
+			body:      "<script>alert(1)</script>",
+			forbidden: []string{"<script"},
+			want:      []string{"&lt;script&gt;"},
+		},
+		{
+			name:   "highlighted code keeps its classes",
+			format: FormatMarkdown,
+			body:   "```go\nfunc main() {}\n```",
+			want:   []string{`<pre class="chroma">`, `<span class="kd">func</span>`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HTML(tt.format, tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.forbidden {
+				if strings.Contains(got, s) {
+					t.Errorf("HTML(%q) = %q, contains %q", tt.body, got, s)
+				}
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(got, s) {
+					t.Errorf("HTML(%q) = %q, missing %q", tt.body, got, s)
+				}
+			}
+		})
+	}
+}
+
+// The policy is the second line of defence behind goldmark, so it must
+// also hold for HTML that reaches it directly.
+func TestPolicy(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{`<p>hi<script>alert(1)</script></p>`, `<p>hi</p>`},
+		{`<a href="javascript:alert(1)">x</a>`, `x`},
+		{`<p onclick="alert(1)">x</p>`, `<p>x</p>`},
+		{`<span class="kd" style="color:red">func</span>`, `<span class="kd">func</span>`},
+		{`<span class="x" onmouseover="alert(1)">y</span>`, `<span class="x">y</span>`},
+	}
+	for _, tt := range tests {
+		if got := policy.Sanitize(tt.html); got != tt.want {
+			t.Errorf("Sanitize(%q) = %q, want %q", tt.html, got, tt.want)
+		}
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		text  string
+		limit int
+		want  string
+	}{
+		{"short", 10, "short"},
+		{"hello world again", 13, "hello world…"},
+		{"ääää ääää ääää", 12, "ääää ääää…"},
+		{"äää ääääääää", 10, "äää ääääää…"},
+		{"ääääää", 3, "äää…"},
+	}
+	for _, tt := range tests {
+		if got := Excerpt("<p>"+tt.text+"</p>", tt.limit); got != tt.want {
+			t.Errorf("Excerpt(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.want)
+		}
+	}
+}
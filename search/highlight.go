@@ -0,0 +1,50 @@
+package search
+
+import (
+	"html"
+	"strings"
+)
+
+// snippetContext is the number of tokens kept before the first match.
+const snippetContext = 8
+
+// highlight HTML-escapes text and wraps tokens in matched with <mark>.
+// When maxTokens > 0 the output is cut to a window of that many tokens
+// starting shortly before the first match.
+func highlight(text string, matched map[string]bool, maxTokens int) string {
+	tokens := tokenize(text)
+	from, to := 0, len(tokens)
+	if maxTokens > 0 && len(tokens) > maxTokens {
+		first := 0
+		for i, t := range tokens {
+			if matched[t.term] {
+				first = i
+				break
+			}
+		}
+		from = max(0, first-snippetContext)
+		to = min(len(tokens), from+maxTokens)
+	}
+
+	var b strings.Builder
+	offset := 0
+	if from > 0 {
+		b.WriteString("… ")
+		offset = tokens[from].start
+	}
+	for _, t := range tokens[from:to] {
+		b.WriteString(html.EscapeString(text[offset:t.start]))
+		if matched[t.term] {
+			b.WriteString("<mark>" + html.EscapeString(text[t.start:t.end]) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(text[t.start:t.end]))
+		}
+		offset = t.end
+	}
+	if to < len(tokens) {
+		b.WriteString(" …")
+	} else {
+		b.WriteString(html.EscapeString(text[offset:]))
+	}
+	return b.String()
+}
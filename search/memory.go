@@ -0,0 +1,234 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BM25 parameters and the relative weight of a title match over a body match.
+const (
+	bm25K1      = 1.2
+	bm25B       = 0.75
+	titleWeight = 2.0
+	snippetSize = 30
+)
+
+// field is the inverted index of one document field.
+type field struct {
+	weight   float64
+	postings map[string]map[uint][]int // term -> doc -> positions
+	lengths  map[uint]int
+	total    int
+}
+
+func newField(weight float64) *field {
+	return &field{
+		weight:   weight,
+		postings: map[string]map[uint][]int{},
+		lengths:  map[uint]int{},
+	}
+}
+
+func (f *field) add(id uint, text string) {
+	tokens := terms(text)
+	for pos, term := range tokens {
+		docs, ok := f.postings[term]
+		if !ok {
+			docs = map[uint][]int{}
+			f.postings[term] = docs
+		}
+		docs[id] = append(docs[id], pos)
+	}
+	f.lengths[id] = len(tokens)
+	f.total += len(tokens)
+}
+
+func (f *field) remove(id uint, text string) {
+	for _, term := range terms(text) {
+		if docs, ok := f.postings[term]; ok {
+			delete(docs, id)
+			if len(docs) == 0 {
+				delete(f.postings, term)
+			}
+		}
+	}
+	f.total -= f.lengths[id]
+	delete(f.lengths, id)
+}
+
+// score is the weighted BM25 contribution of a term occurring tf times in doc id.
+func (f *field) score(term string, id uint, tf int) float64 {
+	n := float64(len(f.lengths))
+	df := float64(len(f.postings[term]))
+	idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+	avg := float64(f.total) / n
+	norm := bm25K1 * (1 - bm25B + bm25B*float64(f.lengths[id])/avg)
+	return f.weight * idf * float64(tf) * (bm25K1 + 1) / (float64(tf) + norm)
+}
+
+// MemoryIndex is an in-process Searcher. It keeps every document in
+// memory and is safe for concurrent use. Each process holds its own
+// copy, so running several instances needs a shared Searcher instead.
+type MemoryIndex struct {
+	mu    sync.RWMutex
+	docs  map[uint]Document
+	title *field
+	body  *field
+}
+
+// NewMemoryIndex returns an empty in-process index.
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		docs:  map[uint]Document{},
+		title: newField(titleWeight),
+		body:  newField(1),
+	}
+}
+
+func (m *MemoryIndex) Index(doc Document) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(doc.ID)
+	m.docs[doc.ID] = doc
+	m.title.add(doc.ID, doc.Title)
+	m.body.add(doc.ID, doc.Body)
+	return nil
+}
+
+func (m *MemoryIndex) Remove(id uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(id)
+	return nil
+}
+
+func (m *MemoryIndex) remove(id uint) {
+	doc, ok := m.docs[id]
+	if !ok {
+		return
+	}
+	m.title.remove(id, doc.Title)
+	m.body.remove(id, doc.Body)
+	delete(m.docs, id)
+}
+
+func (m *MemoryIndex) Search(q Query) ([]Hit, int, error) {
+	clauses := parseQuery(q.Text)
+	if len(clauses) == 0 {
+		return []Hit{}, 0, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Every clause must match; scores add up across clauses
+	matched := map[string]bool{}
+	var scores map[uint]float64
+	for _, cl := range clauses {
+		clauseScores := map[uint]float64{}
+		for _, f := range []*field{m.title, m.body} {
+			for id, s := range m.matchClause(f, cl, matched) {
+				clauseScores[id] += s
+			}
+		}
+		if scores == nil {
+			scores = clauseScores
+			continue
+		}
+		for id := range scores {
+			if s, ok := clauseScores[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		doc := m.docs[id]
+		if !doc.Published && doc.AccountID != q.ViewerID {
+			continue
+		}
+		hits = append(hits, Hit{ID: id, AccountID: doc.AccountID, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID > hits[j].ID
+	})
+
+	total := len(hits)
+	from := min(max(q.Offset, 0), total)
+	to := total
+	if q.Limit > 0 {
+		to = min(from+q.Limit, total)
+	}
+	page := hits[from:to]
+	for i := range page {
+		doc := m.docs[page[i].ID]
+		page[i].Title = highlight(doc.Title, matched, 0)
+		page[i].Snippet = highlight(doc.Body, matched, snippetSize)
+	}
+	return page, total, nil
+}
+
+// matchClause scores the documents in which f satisfies cl, and records
+// the terms it matched for highlighting.
+func (m *MemoryIndex) matchClause(f *field, cl clause, matched map[string]bool) map[uint]float64 {
+	scores := map[uint]float64{}
+	if len(cl.terms) == 1 {
+		candidates := []string{cl.terms[0]}
+		if cl.prefix {
+			candidates = candidates[:0]
+			for term := range f.postings {
+				if strings.HasPrefix(term, cl.terms[0]) {
+					candidates = append(candidates, term)
+				}
+			}
+		}
+		for _, term := range candidates {
+			for id, positions := range f.postings[term] {
+				scores[id] += f.score(term, id, len(positions))
+				matched[term] = true
+			}
+		}
+		return scores
+	}
+
+	// Phrase: look for the remaining terms at consecutive positions
+	for id, starts := range f.postings[cl.terms[0]] {
+		occurrences := 0
+		for _, start := range starts {
+			if phraseAt(f, id, cl.terms, start) {
+				occurrences++
+			}
+		}
+		if occurrences == 0 {
+			continue
+		}
+		for _, term := range cl.terms {
+			scores[id] += f.score(term, id, occurrences)
+			matched[term] = true
+		}
+	}
+	return scores
+}
+
+func phraseAt(f *field, id uint, phrase []string, start int) bool {
+	for offset, term := range phrase[1:] {
+		if !containsInt(f.postings[term][id], start+offset+1) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsInt reports whether sorted contains v.
+func containsInt(sorted []int, v int) bool {
+	i := sort.SearchInts(sorted, v)
+	return i < len(sorted) && sorted[i] == v
+}
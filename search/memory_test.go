@@ -0,0 +1,125 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func newTestIndex(t *testing.T, docs ...Document) *MemoryIndex {
+	t.Helper()
+	index := NewMemoryIndex()
+	for _, doc := range docs {
+		doc.Published = true
+		if err := index.Index(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return index
+}
+
+// search runs text against index and returns every hit in order.
+func search(t *testing.T, index *MemoryIndex, text string) []Hit {
+	t.Helper()
+	hits, total, err := index.Search(Query{Text: text})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != len(hits) {
+		t.Fatalf("Search(%q) total = %d for %d hits", text, total, len(hits))
+	}
+	return hits
+}
+
+func hitIDs(hits []Hit) string {
+	ids := make([]string, len(hits))
+	for i, hit := range hits {
+		ids[i] = fmt.Sprint(hit.ID)
+	}
+	return strings.Join(ids, " ")
+}
+
+func TestMemoryIndexRanking(t *testing.T) {
+	index := newTestIndex(t,
+		Document{ID: 1, Title: "Notes", Body: "a gopher among many other words in a rather long body of text"},
+		Document{ID: 2, Title: "Notes", Body: "gopher gopher"},
+		Document{ID: 3, Title: "Gopher", Body: "short"},
+		Document{ID: 4, Title: "Unrelated", Body: "nothing to see"},
+	)
+	// A title match outweighs body matches, and in bodies more
+	// occurrences in a shorter text rank higher
+	if got := hitIDs(search(t, index, "gopher")); got != "3 2 1" {
+		t.Fatalf("ranking = %s, want 3 2 1", got)
+	}
+	if got := hitIDs(search(t, index, "gopher notes")); got != "2 1" {
+		t.Fatalf("every term must match: got %s, want 2 1", got)
+	}
+}
+
+func TestMemoryIndexPhrase(t *testing.T) {
+	index := newTestIndex(t,
+		Document{ID: 1, Title: "One", Body: "the quick brown fox"},
+		Document{ID: 2, Title: "Two", Body: "brown and quick, the fox"},
+	)
+	if got := hitIDs(search(t, index, `"quick brown"`)); got != "1" {
+		t.Fatalf("phrase hits = %s, want only 1", got)
+	}
+	if got := hitIDs(search(t, index, `"brown quick"`)); got != "" {
+		t.Fatalf("reversed phrase hits = %s, want none", got)
+	}
+	if got := len(search(t, index, "quick brown")); got != 2 {
+		t.Fatalf("separate terms matched %d documents, want 2", got)
+	}
+}
+
+func TestMemoryIndexPrefix(t *testing.T) {
+	index := newTestIndex(t,
+		Document{ID: 1, Title: "One", Body: "a gopher"},
+		Document{ID: 2, Title: "Two", Body: "many gophers"},
+		Document{ID: 3, Title: "Three", Body: "let us go"},
+	)
+	hits := search(t, index, "goph*")
+	if len(hits) != 2 {
+		t.Fatalf("prefix hits = %s, want 1 and 2", hitIDs(hits))
+	}
+	for _, hit := range hits {
+		if !strings.Contains(hit.Snippet, "<mark>goph") {
+			t.Fatalf("snippet %q does not mark the prefix match", hit.Snippet)
+		}
+	}
+	if got := len(search(t, index, "goph")); got != 0 {
+		t.Fatalf("a bare term matched %d documents as a prefix", got)
+	}
+}
+
+func TestMemoryIndexHighlightMultiByte(t *testing.T) {
+	long := strings.Repeat("wörter ", 40) + "ziel " + strings.Repeat("ünd ", 40)
+	index := newTestIndex(t,
+		Document{ID: 1, Title: "Über Straße", Body: "Café Müller & Straße"},
+		Document{ID: 2, Title: "Lang", Body: long},
+	)
+
+	hits := search(t, index, "STRAẞE müller")
+	if len(hits) != 1 {
+		t.Fatalf("hits = %s, want 1", hitIDs(hits))
+	}
+	if want := "Über <mark>Straße</mark>"; hits[0].Title != want {
+		t.Fatalf("title = %q, want %q", hits[0].Title, want)
+	}
+	if want := "Café <mark>Müller</mark> &amp; <mark>Straße</mark>"; hits[0].Snippet != want {
+		t.Fatalf("snippet = %q, want %q", hits[0].Snippet, want)
+	}
+
+	hits = search(t, index, "ziel")
+	if len(hits) != 1 {
+		t.Fatalf("hits = %s, want 2", hitIDs(hits))
+	}
+	snippet := hits[0].Snippet
+	if !utf8.ValidString(snippet) || !strings.HasPrefix(snippet, "… wörter") || !strings.HasSuffix(snippet, "ünd …") {
+		t.Fatalf("snippet = %q, want a window cut at token boundaries", snippet)
+	}
+	if !strings.Contains(snippet, "wörter <mark>ziel</mark> ünd") {
+		t.Fatalf("snippet = %q, want ziel marked", snippet)
+	}
+}
@@ -0,0 +1,22 @@
+package search
+
+import (
+	"genesis/models"
+	"genesis/render"
+)
+
+// PostDocument maps a post onto its search representation. Markdown
+// bodies are indexed as their rendered text so syntax isn't searchable.
+func PostDocument(post models.Post) Document {
+	body := post.Body
+	if post.Format == render.FormatMarkdown && post.BodyHTML != "" {
+		body = render.PlainText(post.BodyHTML)
+	}
+	return Document{
+		ID:        post.ID,
+		AccountID: post.AccountID,
+		Title:     post.Title,
+		Body:      body,
+		Published: !post.Draft,
+	}
+}
@@ -0,0 +1,41 @@
+// Package search provides full-text search over posts.
+package search
+
+// Document is the searchable view of a post.
+type Document struct {
+	ID        uint
+	AccountID uint
+	Title     string
+	Body      string
+	Published bool
+}
+
+// Query describes a search request. Text supports bare terms, "quoted
+// phrases" and prefix terms ending in *; every clause must match.
+// Unpublished documents are only returned to their author, ViewerID.
+type Query struct {
+	Text     string
+	ViewerID uint
+	Limit    int
+	Offset   int
+}
+
+// Hit is a ranked search result. Title and Snippet are HTML escaped with
+// matches wrapped in <mark> tags.
+type Hit struct {
+	ID        uint    `json:"id"`
+	AccountID uint    `json:"accountID"`
+	Score     float64 `json:"score"`
+	Title     string  `json:"title"`
+	Snippet   string  `json:"snippet"`
+}
+
+// Searcher indexes posts and answers ranked queries against them.
+type Searcher interface {
+	// Index adds doc or replaces the document with the same ID.
+	Index(doc Document) error
+	// Remove drops a document; removing an unknown ID is not an error.
+	Remove(id uint) error
+	// Search returns one page of hits and the total number of matches.
+	Search(q Query) ([]Hit, int, error)
+}
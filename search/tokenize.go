@@ -0,0 +1,84 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// token is a normalized term and its byte range in the source text.
+type token struct {
+	term       string
+	start, end int
+}
+
+// tokenize splits text into lowercase runs of letters and digits.
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if word && start < 0 {
+			start = i
+		} else if !word && start >= 0 {
+			tokens = append(tokens, token{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return tokens
+}
+
+func terms(text string) []string {
+	tokens := tokenize(text)
+	out := make([]string, len(tokens))
+	for i, t := range tokens {
+		out[i] = t.term
+	}
+	return out
+}
+
+// clause is one required part of a parsed query: a single term, a prefix
+// or a phrase of consecutive terms.
+type clause struct {
+	terms  []string
+	prefix bool
+}
+
+// parseQuery splits query text into clauses. Unbalanced quotes run to the
+// end of the text.
+func parseQuery(text string) []clause {
+	var clauses []clause
+	for len(text) > 0 {
+		r, size := utf8.DecodeRuneInString(text)
+		switch {
+		case r == '"':
+			text = text[size:]
+			end := strings.IndexByte(text, '"')
+			if end < 0 {
+				end = len(text)
+			}
+			if phrase := terms(text[:end]); len(phrase) > 0 {
+				clauses = append(clauses, clause{terms: phrase})
+			}
+			text = text[min(end+1, len(text)):]
+		case unicode.IsSpace(r):
+			text = text[size:]
+		default:
+			end := strings.IndexFunc(text, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+			if end < 0 {
+				end = len(text)
+			}
+			word := text[:end]
+			text = text[end:]
+			prefix := strings.HasSuffix(word, "*")
+			parts := terms(word)
+			for i, part := range parts {
+				clauses = append(clauses, clause{terms: []string{part}, prefix: prefix && i == len(parts)-1})
+			}
+		}
+	}
+	return clauses
+}
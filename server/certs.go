@@ -0,0 +1,74 @@
+package server
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// certCheckInterval is how often the certificate files are checked for
+// changes, at most.
+const certCheckInterval = 30 * time.Second
+
+// CertReloader serves a certificate from files and reloads it when
+// either file changes, so renewed certificates are used without a
+// restart.
+type CertReloader struct {
+	certFile, keyFile string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// NewCertReloader loads the key pair, failing if it is unusable.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate is used as tls.Config.GetCertificate. A certificate
+// that fails to reload is logged and the previous one kept.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checkedAt) >= certCheckInterval {
+		r.checkedAt = time.Now()
+		if r.latestModTime().After(r.modTime) {
+			if err := r.load(); err != nil {
+				slog.Error("Failed to reload TLS certificate", "file", r.certFile, "error", err)
+			} else {
+				slog.Info("Reloaded TLS certificate", "file", r.certFile)
+			}
+		}
+	}
+	return r.cert, nil
+}
+
+// load reads the key pair; r.mu must be held once the reloader is in use.
+func (r *CertReloader) load() error {
+	modTime := r.latestModTime()
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert, r.modTime, r.checkedAt = &cert, modTime, time.Now()
+	return nil
+}
+
+// latestModTime is the newer modification time of the two files.
+func (r *CertReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		if info, err := os.Stat(name); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
@@ -0,0 +1,92 @@
+// Package server runs the HTTP server with timeouts, optional TLS and a
+// graceful shutdown.
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"genesis/config"
+)
+
+// Server is an http.Server that drains in-flight requests when stopped.
+type Server struct {
+	http            *http.Server
+	tls             bool
+	shutdownTimeout time.Duration
+}
+
+// New returns a server for handler configured by cfg. With TLS enabled the
+// certificate is loaded now, so a bad one fails at startup.
+func New(cfg config.Server, handler http.Handler) (*Server, error) {
+	s := &Server{
+		http: &http.Server{
+			Addr:              cfg.Addr,
+			Handler:           handler,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			ReadTimeout:       cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+		},
+		shutdownTimeout: cfg.ShutdownTimeout,
+	}
+	if cfg.TLS.Enabled() {
+		certs, err := NewCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		s.http.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.GetCertificate,
+		}
+		s.tls = true
+	}
+	return s, nil
+}
+
+// OnShutdown registers f to run when shutdown starts, alongside the
+// drain. It is meant for closing long-lived connections such as event
+// streams and WebSockets, which the drain does not end by itself.
+func (s *Server) OnShutdown(f func()) {
+	s.http.RegisterOnShutdown(f)
+}
+
+// Run serves until ctx is done, then stops accepting connections and
+// waits for in-flight requests for up to the shutdown timeout before
+// closing the rest. It returns once the server has stopped.
+func (s *Server) Run(ctx context.Context) error {
+	errc := make(chan error, 1)
+	go func() {
+		var err error
+		if s.tls {
+			err = s.http.ListenAndServeTLS("", "")
+		} else {
+			err = s.http.ListenAndServe()
+		}
+		errc <- err
+	}()
+	slog.Info("Listening", "addr", s.http.Addr, "tls", s.tls)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down, draining requests", "timeout", s.shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	err := s.http.Shutdown(shutdownCtx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		slog.Warn("Requests still running after the shutdown timeout, closing them", "timeout", s.shutdownTimeout)
+		err = s.http.Close()
+	}
+	if serveErr := <-errc; !errors.Is(serveErr, http.ErrServerClosed) {
+		return serveErr
+	}
+	return err
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is an in-memory stand-in for the path-style S3 calls S3Store
+// makes. It does not check signatures.
+type fakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]bool
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if key == "" && r.Method == http.MethodPut {
+		f.buckets[bucket] = true
+		return
+	}
+	if !f.buckets[bucket] {
+		s3Fail(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	path := bucket + "/" + key
+	switch {
+	case key == "" && r.Method == http.MethodHead:
+	case r.Method == http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			s3Fail(w, r, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		f.objects[path] = body
+		w.Header().Set("ETag", `"etag"`)
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		body, ok := f.objects[path]
+		if !ok {
+			s3Fail(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Last-Modified", "Mon, 19 Oct 2026 00:00:00 GMT")
+		if r.Method == http.MethodGet {
+			w.Write(body)
+		}
+	case r.Method == http.MethodDelete:
+		delete(f.objects, path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s3Fail(w, r, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func s3Fail(w http.ResponseWriter, r *http.Request, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		io.WriteString(w, "<Error><Code>"+code+"</Code></Error>")
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	fake := &fakeS3{buckets: map[string]bool{}, objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	ctx := context.Background()
+	blobs, err := NewS3Store(ctx, S3Config{
+		Endpoint: strings.TrimPrefix(server.URL, "http://"),
+		Region:   "us-east-1",
+		Bucket:   "blobs",
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store: %v", err)
+	}
+	if !fake.buckets["blobs"] {
+		t.Fatal("NewS3Store did not create the missing bucket")
+	}
+	if err := blobs.Ping(ctx); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+
+	if err := blobs.Put(ctx, "abc", strings.NewReader("hello"), 5, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if ok, err := blobs.Exists(ctx, "abc"); !ok || err != nil {
+		t.Fatalf("Exists after Put = %v, %v; want true", ok, err)
+	}
+	r, err := blobs.Get(ctx, "abc")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(got) != "hello" {
+		t.Fatalf("Get read %q, %v; want hello", got, err)
+	}
+
+	if err := blobs.Delete(ctx, "abc"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if ok, err := blobs.Exists(ctx, "abc"); ok || err != nil {
+		t.Fatalf("Exists after Delete = %v, %v; want false", ok, err)
+	}
+	if _, err := blobs.Get(ctx, "abc"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Delete = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,22 @@
+// Package storage holds uploaded file contents behind a BlobStore.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned when a blob does not exist.
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore saves and serves opaque blobs addressed by key. Keys are
+// content hashes, so writing an existing key again is harmless.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	Delete(ctx context.Context, key string) error
+	// Ping reports whether the store can be reached.
+	Ping(ctx context.Context) error
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"genesis/models"
+	"genesis/permalink"
+
+	"gorm.io/gorm"
+)
+
+type gormAccounts struct {
+	db *gorm.DB
+}
+
+func (s gormAccounts) Get(id uint) (models.Account, error) {
+	var account models.Account
+	err := s.db.First(&account, id).Error
+	return account, notFound(err)
+}
+
+func (s gormAccounts) GetWithPosts(id uint) (models.Account, error) {
+	var account models.Account
+	err := s.db.Preload("Posts").First(&account, id).Error
+	return account, notFound(err)
+}
+
+func (s gormAccounts) GetByEmail(email string) (models.Account, error) {
+	var account models.Account
+	err := s.db.Where("email = ?", email).First(&account).Error
+	return account, notFound(err)
+}
+
+func (s gormAccounts) GetByHandle(handle string) (models.Account, error) {
+	var account models.Account
+	err := s.db.Where("handle = ?", handle).First(&account).Error
+	return account, notFound(err)
+}
+
+func (s gormAccounts) Handles(ids []uint) (map[uint]string, error) {
+	handles := map[uint]string{}
+	if len(ids) == 0 {
+		return handles, nil
+	}
+	var accounts []models.Account
+	if err := s.db.Select("id, handle").Where("id IN ?", ids).Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	for _, account := range accounts {
+		handles[account.ID] = account.Handle
+	}
+	return handles, nil
+}
+
+func (s gormAccounts) HandleTaken(handle string) (bool, error) {
+	var taken int64
+	err := s.db.Unscoped().Model(&models.Account{}).Where("handle = ?", handle).Count(&taken).Error
+	return taken > 0, err
+}
+
+func (s gormAccounts) FreeHandle(email string) (string, error) {
+	return permalink.Handle(s.db, email)
+}
+
+func (s gormAccounts) Create(account *models.Account) error {
+	return s.db.Create(account).Error
+}
+
+func (s gormAccounts) UpdateEmail(id uint, email string) error {
+	result := s.db.Model(&models.Account{}).Where("id = ?", id).Update("email", email)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return result.Error
+}
+
+func (s gormAccounts) Delete(id uint) ([]uint, error) {
+	var postIDs []uint
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Post{}).Where("account_id = ?", id).Pluck("id", &postIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("account_id = ?", id).Delete(&models.Post{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Account{}, id).Error
+	})
+	return postIDs, err
+}
+
+// liveAccounts selects the IDs of accounts that are not deleted, for
+// leaving out posts whose author is gone.
+func liveAccounts(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Account{}).Select("id")
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"genesis/models"
+
+	"gorm.io/gorm"
+)
+
+type gormAttachments struct {
+	db *gorm.DB
+}
+
+func (s gormAttachments) Get(id uint) (models.Attachment, error) {
+	var attachment models.Attachment
+	err := s.db.First(&attachment, id).Error
+	return attachment, notFound(err)
+}
+
+func (s gormAttachments) GetByHash(accountID uint, hash string) (models.Attachment, error) {
+	var attachment models.Attachment
+	err := s.db.Where("account_id = ? AND hash = ?", accountID, hash).Preload("Variants").First(&attachment).Error
+	return attachment, notFound(err)
+}
+
+func (s gormAttachments) List(accountID uint) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	err := s.db.Where("account_id = ?", accountID).Preload("Variants").Order("id DESC").Find(&attachments).Error
+	return attachments, err
+}
+
+func (s gormAttachments) Usage(accountID uint) (int64, error) {
+	var uploads, variants int64
+	if err := s.db.Model(&models.Attachment{}).Where("account_id = ?", accountID).
+		Select("COALESCE(SUM(size), 0)").Scan(&uploads).Error; err != nil {
+		return 0, err
+	}
+	err := s.db.Model(&models.AttachmentVariant{}).
+		Joins("JOIN attachments ON attachments.id = attachment_variants.attachment_id").
+		Where("attachments.account_id = ?", accountID).
+		Select("COALESCE(SUM(attachment_variants.size), 0)").Scan(&variants).Error
+	return uploads + variants, err
+}
+
+func (s gormAttachments) Create(attachment *models.Attachment, quota int64) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// Writing the account row locks it until commit, so a second
+		// upload by the same account waits here for this one's total
+		if err := tx.Model(&models.Account{}).Where("id = ?", attachment.AccountID).
+			UpdateColumn("updated_at", gorm.Expr("updated_at")).Error; err != nil {
+			return err
+		}
+		used, err := gormAttachments{tx}.Usage(attachment.AccountID)
+		if err != nil {
+			return err
+		}
+		if used+attachment.Size > quota {
+			return ErrQuotaExceeded
+		}
+		return conflict(tx.Create(attachment).Error)
+	})
+}
+
+func (s gormAttachments) Delete(id uint) error {
+	return s.db.Delete(&models.Attachment{}, id).Error
+}
+
+func (s gormAttachments) Variant(attachmentID uint, name string) (models.AttachmentVariant, error) {
+	var variant models.AttachmentVariant
+	err := s.db.Where("attachment_id = ? AND name = ?", attachmentID, name).First(&variant).Error
+	return variant, notFound(err)
+}
+
+func (s gormAttachments) Published(id uint) (bool, error) {
+	var published int64
+	err := s.db.Table("post_attachments").
+		Joins("JOIN posts ON posts.id = post_attachments.post_id AND posts.deleted_at IS NULL AND posts.draft = ?", false).
+		Where("post_attachments.attachment_id = ?", id).Count(&published).Error
+	return published > 0, err
+}
+
+func (s gormAttachments) InUse(id uint) (bool, error) {
+	var refs int64
+	err := s.db.Table("post_attachments").Where("attachment_id = ?", id).Count(&refs).Error
+	return refs > 0, err
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"genesis/models"
+
+	"gorm.io/gorm"
+)
+
+type gormComments struct {
+	db *gorm.DB
+}
+
+func (s gormComments) Get(id uint) (models.Comment, error) {
+	var comment models.Comment
+	err := s.db.First(&comment, id).Error
+	return comment, notFound(err)
+}
+
+func (s gormComments) Create(comment *models.Comment) error {
+	return s.db.Create(comment).Error
+}
+
+func (s gormComments) Threads(postID uint, offset, limit int) ([]models.Comment, int64, error) {
+	roots := s.db.Model(&models.Comment{}).Where("post_id = ? AND parent_id IS NULL", postID)
+	var total int64
+	if err := roots.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var threads []models.Comment
+	if err := roots.Order("created_at, id").Offset(offset).Limit(limit).Find(&threads).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Load replies one nesting level at a time
+	children := map[uint][]models.Comment{}
+	parentIDs := make([]uint, len(threads))
+	for i, root := range threads {
+		parentIDs[i] = root.ID
+	}
+	for len(parentIDs) > 0 {
+		var replies []models.Comment
+		if err := s.db.Where("parent_id IN ?", parentIDs).Order("created_at, id").Find(&replies).Error; err != nil {
+			return nil, 0, err
+		}
+		parentIDs = parentIDs[:0]
+		for _, reply := range replies {
+			children[*reply.ParentID] = append(children[*reply.ParentID], reply)
+			parentIDs = append(parentIDs, reply.ID)
+		}
+	}
+	for i := range threads {
+		attachReplies(&threads[i], children)
+	}
+	return threads, total, nil
+}
+
+func attachReplies(comment *models.Comment, children map[uint][]models.Comment) {
+	comment.Replies = children[comment.ID]
+	for i := range comment.Replies {
+		attachReplies(&comment.Replies[i], children)
+	}
+}
+
+func (s gormComments) UpdateBody(id uint, body string) error {
+	return s.db.Model(&models.Comment{}).Where("id = ?", id).Update("body", body).Error
+}
+
+// DeleteThread removes the subtree itself, since soft deletes don't
+// trigger the database cascade.
+func (s gormComments) DeleteThread(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		ids := []uint{id}
+		for frontier := ids; len(frontier) > 0; {
+			var next []uint
+			if err := tx.Model(&models.Comment{}).Where("parent_id IN ?", frontier).Pluck("id", &next).Error; err != nil {
+				return err
+			}
+			ids = append(ids, next...)
+			frontier = next
+		}
+		return tx.Delete(&models.Comment{}, ids).Error
+	})
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"genesis/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormFollows struct {
+	db *gorm.DB
+}
+
+func (s gormFollows) Follow(followerID, followeeID uint) (bool, error) {
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.Follow{FollowerID: followerID, FolloweeID: followeeID})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (s gormFollows) Unfollow(followerID, followeeID uint) (bool, error) {
+	result := s.db.Where("follower_id = ? AND followee_id = ?", followerID, followeeID).Delete(&models.Follow{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (s gormFollows) Followers(accountID uint, offset, limit int) ([]FollowEntry, int64, error) {
+	return s.list("followee_id", "follower_id", accountID, offset, limit)
+}
+
+func (s gormFollows) Following(accountID uint, offset, limit int) ([]FollowEntry, int64, error) {
+	return s.list("follower_id", "followee_id", accountID, offset, limit)
+}
+
+// list pages through the follows whose by column is accountID, returning
+// the accounts on the other side.
+func (s gormFollows) list(by, other string, accountID uint, offset, limit int) ([]FollowEntry, int64, error) {
+	var total int64
+	if err := s.db.Model(&models.Follow{}).Where(by+" = ?", accountID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	entries := []FollowEntry{}
+	err := s.db.Table("follows").
+		Select("accounts.id, accounts.handle, follows.created_at AS followed_at").
+		Joins("JOIN accounts ON accounts.id = follows."+other+" AND accounts.deleted_at IS NULL").
+		Where("follows."+by+" = ?", accountID).
+		Order("follows.created_at DESC").
+		Offset(offset).Limit(limit).
+		Scan(&entries).Error
+	return entries, total, err
+}
+
+func (s gormFollows) FollowerIDs(accountID uint) ([]uint, error) {
+	var followers []uint
+	err := s.db.Model(&models.Follow{}).Where("followee_id = ?", accountID).Pluck("follower_id", &followers).Error
+	return followers, err
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"genesis/database"
+
+	"gorm.io/gorm"
+)
+
+// Gorm implements Store on a GORM database handle.
+type Gorm struct {
+	db *gorm.DB
+}
+
+// NewGorm returns a Store backed by db.
+func NewGorm(db *gorm.DB) *Gorm {
+	return &Gorm{db: db}
+}
+
+func (g *Gorm) Accounts() AccountStore       { return gormAccounts{g.db} }
+func (g *Gorm) Posts() PostStore             { return gormPosts{g.db} }
+func (g *Gorm) Comments() CommentStore       { return gormComments{g.db} }
+func (g *Gorm) Reactions() ReactionStore     { return gormReactions{g.db} }
+func (g *Gorm) Tags() TagStore               { return gormTags{g.db} }
+func (g *Gorm) Attachments() AttachmentStore { return gormAttachments{g.db} }
+func (g *Gorm) Follows() FollowStore         { return gormFollows{g.db} }
+func (g *Gorm) Imports() ImportStore         { return gormImports{g.db} }
+func (g *Gorm) Wallets() WalletStore         { return gormWallets{g.db} }
+
+func (g *Gorm) Transaction(fn func(Store) error) error {
+	return g.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&Gorm{db: tx})
+	})
+}
+
+func (g *Gorm) WithContext(ctx context.Context) Store {
+	return &Gorm{db: g.db.WithContext(ctx)}
+}
+
+func (g *Gorm) Ping(ctx context.Context) error {
+	sqlDB, err := g.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func (g *Gorm) SchemaVersion(ctx context.Context) (int, error) {
+	return database.Version(ctx, g.db)
+}
+
+// notFound maps GORM's missing-record error onto ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// conflict maps GORM's duplicate-key error onto ErrConflict.
+func conflict(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrConflict
+	}
+	return err
+}
@@ -0,0 +1,40 @@
+package store
+
+import (
+	"genesis/models"
+
+	"gorm.io/gorm"
+)
+
+type gormImports struct {
+	db *gorm.DB
+}
+
+func (s gormImports) Create(job *models.ImportJob) error {
+	return s.db.Create(job).Error
+}
+
+func (s gormImports) Get(accountID, id uint) (models.ImportJob, error) {
+	var job models.ImportJob
+	err := s.db.Where("account_id = ?", accountID).First(&job, id).Error
+	return job, notFound(err)
+}
+
+func (s gormImports) Load(id uint) (models.ImportJob, error) {
+	var job models.ImportJob
+	err := s.db.First(&job, id).Error
+	return job, notFound(err)
+}
+
+func (s gormImports) Save(job *models.ImportJob) error {
+	return s.db.Save(job).Error
+}
+
+func (s gormImports) SetStatus(id uint, status string) error {
+	return s.db.Model(&models.ImportJob{}).Where("id = ?", id).Update("status", status).Error
+}
+
+func (s gormImports) MoveStatus(from []string, status string) (int64, error) {
+	result := s.db.Model(&models.ImportJob{}).Where("status IN ?", from).Update("status", status)
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,269 @@
+package store
+
+import (
+	"time"
+
+	"genesis/models"
+	"genesis/permalink"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormPosts struct {
+	db *gorm.DB
+}
+
+func (s gormPosts) first(query *gorm.DB) (models.Post, error) {
+	var post models.Post
+	err := query.Preload("Tags").Preload("Attachments.Variants").First(&post).Error
+	return post, notFound(err)
+}
+
+func (s gormPosts) Get(id uint) (models.Post, error) {
+	return s.first(s.db.Where("id = ?", id))
+}
+
+func (s gormPosts) GetBySlug(accountID uint, slug string) (models.Post, error) {
+	return s.first(s.db.Where("account_id = ? AND slug = ?", accountID, slug))
+}
+
+func (s gormPosts) RedirectTarget(accountID uint, slug string) (uint, error) {
+	var redirect models.PostSlugRedirect
+	err := s.db.Where("account_id = ? AND slug = ?", accountID, slug).First(&redirect).Error
+	return redirect.PostID, notFound(err)
+}
+
+func (s gormPosts) List(filter PostFilter) ([]models.Post, error) {
+	query := s.db
+	if filter.AccountID != 0 {
+		query = query.Where("account_id = ?", filter.AccountID)
+	}
+	if filter.PublishedOnly {
+		query = query.Where("draft = ?", false)
+	}
+	if filter.IDs != nil {
+		if len(filter.IDs) == 0 {
+			return []models.Post{}, nil
+		}
+		query = query.Where("id IN ?", filter.IDs)
+	}
+	if len(filter.Tags) > 0 {
+		query = filterByTags(s.db, query, filter.AccountID, filter.Tags, filter.MatchAll)
+	}
+	var posts []models.Post
+	err := query.Preload("Tags").Preload("Attachments.Variants").Find(&posts).Error
+	return posts, err
+}
+
+// postColumns are the columns Recent loads, leaving out comment settings.
+const postColumns = "id, title, slug, body, format, body_html, account_id, draft, version, created_at, updated_at"
+
+func (s gormPosts) Recent(accountID uint, limit int) ([]models.Post, error) {
+	query := s.db.Select(postColumns).Where("draft = ? AND account_id IN (?)", false, liveAccounts(s.db))
+	if accountID != 0 {
+		query = query.Where("account_id = ?", accountID)
+	}
+	var posts []models.Post
+	err := query.Order("created_at DESC, id DESC").Limit(limit).Find(&posts).Error
+	return posts, err
+}
+
+func (s gormPosts) Each(accountID uint, fn func(models.Post) error) error {
+	var posts []models.Post
+	return s.db.Where("account_id = ?", accountID).Preload("Tags").Order("id").
+		FindInBatches(&posts, 100, func(_ *gorm.DB, _ int) error {
+			for _, post := range posts {
+				if err := fn(post); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+func (s gormPosts) Create(post *models.Post, tags []string, attachmentIDs []uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if post.Tags, err = resolveTags(tx, post.AccountID, tags); err != nil {
+			return err
+		}
+		if post.Attachments, err = resolveAttachments(tx, post.AccountID, attachmentIDs); err != nil {
+			return err
+		}
+		if post.Slug, err = permalink.PostSlug(tx, post.AccountID, 0, post.Title); err != nil {
+			return err
+		}
+		return tx.Create(post).Error
+	})
+}
+
+func (s gormPosts) Update(current models.Post, updated *models.Post, tags []string, attachmentIDs []uint) error {
+	now := time.Now()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := reslugPost(tx, current, updated); err != nil {
+			return err
+		}
+		result := tx.Model(&models.Post{}).Where("id = ? AND version = ?", current.ID, current.Version).Updates(map[string]interface{}{
+			"title":      updated.Title,
+			"slug":       updated.Slug,
+			"body":       updated.Body,
+			"format":     updated.Format,
+			"body_html":  updated.BodyHTML,
+			"draft":      updated.Draft,
+			"version":    gorm.Expr("version + 1"),
+			"updated_at": now,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrModified
+		}
+		if err := replaceTags(tx, updated, tags); err != nil {
+			return err
+		}
+		return replaceAttachments(tx, updated, attachmentIDs)
+	})
+	if err != nil {
+		return err
+	}
+	updated.Version = current.Version + 1
+	updated.UpdatedAt = now
+	return nil
+}
+
+func (s gormPosts) Delete(post models.Post) error {
+	result := s.db.Where("version = ?", post.Version).Delete(&post)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrModified
+	}
+	return result.Error
+}
+
+func (s gormPosts) UpdateBody(id, version uint, body, bodyHTML string) (uint, error) {
+	result := s.db.Model(&models.Post{}).Where("id = ? AND version = ?", id, version).Updates(map[string]interface{}{
+		"body":      body,
+		"body_html": bodyHTML,
+		"version":   gorm.Expr("version + 1"),
+	})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, ErrModified
+	}
+	return version + 1, nil
+}
+
+func (s gormPosts) LockComments(id uint, locked bool) error {
+	return s.db.Model(&models.Post{}).Where("id = ?", id).Update("comments_locked", locked).Error
+}
+
+func (s gormPosts) trashed(accountID uint) *gorm.DB {
+	return s.db.Unscoped().Where("account_id = ? AND deleted_at IS NOT NULL", accountID)
+}
+
+func (s gormPosts) Trashed(accountID uint, offset, limit int) ([]models.Post, error) {
+	var posts []models.Post
+	err := s.trashed(accountID).Order("deleted_at DESC").Offset(offset).Limit(limit).
+		Preload("Tags").Preload("Attachments.Variants").Find(&posts).Error
+	return posts, err
+}
+
+func (s gormPosts) GetTrashed(accountID, id uint) (models.Post, error) {
+	var post models.Post
+	err := s.trashed(accountID).First(&post, id).Error
+	return post, notFound(err)
+}
+
+func (s gormPosts) Restore(post models.Post) (models.Post, error) {
+	result := s.db.Unscoped().Model(&post).Where("deleted_at IS NOT NULL").Updates(map[string]interface{}{
+		"deleted_at": nil,
+		"version":    gorm.Expr("version + 1"),
+	})
+	if result.Error != nil {
+		return post, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return post, ErrNotFound
+	}
+	return s.Get(post.ID)
+}
+
+func (s gormPosts) IsCollaborator(postID, accountID uint) (bool, error) {
+	var shared int64
+	err := s.db.Model(&models.PostCollaborator{}).Where("post_id = ? AND account_id = ?", postID, accountID).Count(&shared).Error
+	return shared > 0, err
+}
+
+func (s gormPosts) Collaborators(postID uint) ([]Collaborator, error) {
+	collaborators := []Collaborator{}
+	err := s.db.Table("post_collaborators").
+		Select("accounts.id AS account_id, accounts.handle, post_collaborators.created_at AS added_at").
+		Joins("JOIN accounts ON accounts.id = post_collaborators.account_id AND accounts.deleted_at IS NULL").
+		Where("post_collaborators.post_id = ?", postID).Order("post_collaborators.created_at").
+		Scan(&collaborators).Error
+	return collaborators, err
+}
+
+func (s gormPosts) AddCollaborator(postID, accountID uint) error {
+	return s.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.PostCollaborator{PostID: postID, AccountID: accountID}).Error
+}
+
+func (s gormPosts) RemoveCollaborator(postID, accountID uint) error {
+	return s.db.Where("post_id = ? AND account_id = ?", postID, accountID).Delete(&models.PostCollaborator{}).Error
+}
+
+// replaceTags sets the post's tags when names is given, and otherwise
+// loads the current ones.
+func replaceTags(tx *gorm.DB, post *models.Post, names []string) error {
+	if names == nil {
+		return tx.Model(post).Association("Tags").Find(&post.Tags)
+	}
+	tags, err := resolveTags(tx, post.AccountID, names)
+	if err != nil {
+		return err
+	}
+	post.Tags = tags
+	return tx.Model(post).Association("Tags").Replace(tags)
+}
+
+// replaceAttachments is the attachment counterpart of replaceTags.
+func replaceAttachments(tx *gorm.DB, post *models.Post, ids []uint) error {
+	if ids == nil {
+		current := tx.Table("post_attachments").Select("attachment_id").Where("post_id = ?", post.ID)
+		return tx.Where("id IN (?)", current).Preload("Variants").Find(&post.Attachments).Error
+	}
+	attachments, err := resolveAttachments(tx, post.AccountID, ids)
+	if err != nil {
+		return err
+	}
+	post.Attachments = attachments
+	return tx.Model(post).Association("Attachments").Replace(attachments)
+}
+
+// reslugPost gives updated a new slug when its title no longer matches the
+// current one, and keeps the old slug as a redirect.
+func reslugPost(tx *gorm.DB, current models.Post, updated *models.Post) error {
+	if current.Slug != "" && permalink.HasBase(current.Slug, permalink.Base(updated.Title)) {
+		return nil
+	}
+	slug, err := permalink.PostSlug(tx, current.AccountID, current.ID, updated.Title)
+	if err != nil {
+		return err
+	}
+	updated.Slug = slug
+	if current.Slug == "" {
+		return nil
+	}
+	// A title changed back reclaims its slug from the redirects
+	if err := tx.Where("account_id = ? AND slug = ?", current.AccountID, slug).Delete(&models.PostSlugRedirect{}).Error; err != nil {
+		return err
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "account_id"}, {Name: "slug"}},
+		DoUpdates: clause.AssignmentColumns([]string{"post_id"}),
+	}).Create(&models.PostSlugRedirect{AccountID: current.AccountID, Slug: current.Slug, PostID: current.ID}).Error
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"genesis/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormReactions struct {
+	db *gorm.DB
+}
+
+// Add only moves the count when a row is inserted, so repeating it is a no-op.
+func (s gormReactions) Add(postID, accountID uint, reaction string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.Reaction{
+			PostID:    postID,
+			AccountID: accountID,
+			Type:      reaction,
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "post_id"}, {Name: "type"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"count": gorm.Expr("reaction_counts.count + 1")}),
+		}).Create(&models.ReactionCount{PostID: postID, Type: reaction, Count: 1}).Error
+	})
+}
+
+func (s gormReactions) Remove(postID, accountID uint, reaction string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("post_id = ? AND account_id = ? AND type = ?", postID, accountID, reaction).
+			Delete(&models.Reaction{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Model(&models.ReactionCount{}).
+			Where("post_id = ? AND type = ? AND count > 0", postID, reaction).
+			Update("count", gorm.Expr("count - 1")).Error
+	})
+}
+
+func (s gormReactions) Counts(postIDs []uint) ([]models.ReactionCount, error) {
+	var counts []models.ReactionCount
+	err := s.db.Where("post_id IN ? AND count > 0", postIDs).Find(&counts).Error
+	return counts, err
+}
+
+func (s gormReactions) Mine(postIDs []uint, accountID uint) ([]models.Reaction, error) {
+	var mine []models.Reaction
+	err := s.db.Where("post_id IN ? AND account_id = ?", postIDs, accountID).Order("type").Find(&mine).Error
+	return mine, err
+}
@@ -0,0 +1,236 @@
+// Package store is the persistence layer behind the HTTP handlers. The
+// interfaces describe what handlers need from each kind of record; Gorm
+// implements them on top of a *gorm.DB.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"genesis/models"
+)
+
+var (
+	// ErrNotFound is returned when the requested record does not exist.
+	ErrNotFound = errors.New("record not found")
+	// ErrConflict is returned when a write would duplicate a unique record.
+	ErrConflict = errors.New("record already exists")
+	// ErrModified signals that a version-guarded write lost a race with another writer.
+	ErrModified = errors.New("record has been modified")
+	// ErrInvalidAttachment is returned when a post references attachments the author doesn't own.
+	ErrInvalidAttachment = errors.New("invalid attachment")
+	// ErrQuotaExceeded is returned when an upload would take an account past its storage quota.
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+)
+
+// Store gives access to every store and runs work in a transaction.
+type Store interface {
+	Accounts() AccountStore
+	Posts() PostStore
+	Comments() CommentStore
+	Reactions() ReactionStore
+	Tags() TagStore
+	Attachments() AttachmentStore
+	Follows() FollowStore
+	Imports() ImportStore
+	Wallets() WalletStore
+	// Transaction calls fn with a Store whose stores all share one
+	// database transaction, committed when fn returns nil.
+	Transaction(fn func(Store) error) error
+	// WithContext returns a Store whose queries run with ctx, so they are
+	// cancelled and traced along with the request.
+	WithContext(ctx context.Context) Store
+	// Ping reports whether the database can be reached.
+	Ping(ctx context.Context) error
+	// SchemaVersion returns the latest schema version applied to the database.
+	SchemaVersion(ctx context.Context) (int, error)
+}
+
+// AccountStore reads and writes accounts.
+type AccountStore interface {
+	Get(id uint) (models.Account, error)
+	// GetWithPosts loads an account together with its posts.
+	GetWithPosts(id uint) (models.Account, error)
+	GetByEmail(email string) (models.Account, error)
+	GetByHandle(handle string) (models.Account, error)
+	// Handles maps account IDs to handles, leaving out unknown accounts.
+	Handles(ids []uint) (map[uint]string, error)
+	// HandleTaken reports whether any account, deleted ones included, uses handle.
+	HandleTaken(handle string) (bool, error)
+	// FreeHandle derives an unused handle from an email address.
+	FreeHandle(email string) (string, error)
+	Create(account *models.Account) error
+	UpdateEmail(id uint, email string) error
+	// Delete moves an account to the trash together with its posts and
+	// returns the IDs of the posts it trashed.
+	Delete(id uint) ([]uint, error)
+}
+
+// PostFilter narrows PostStore.List.
+type PostFilter struct {
+	// AccountID keeps one author's posts; zero keeps every author's.
+	AccountID     uint
+	PublishedOnly bool
+	// IDs keeps only the listed posts when set.
+	IDs []uint
+	// Tags keeps posts carrying any of the author's tags, or all of them
+	// with MatchAll.
+	Tags     []string
+	MatchAll bool
+}
+
+// Collaborator is a co-author of a post.
+type Collaborator struct {
+	AccountID uint
+	Handle    string
+	AddedAt   time.Time
+}
+
+// PostStore reads and writes posts. Posts are returned with their tags
+// and attachments loaded.
+type PostStore interface {
+	Get(id uint) (models.Post, error)
+	GetBySlug(accountID uint, slug string) (models.Post, error)
+	// RedirectTarget returns the post that used to have slug.
+	RedirectTarget(accountID uint, slug string) (uint, error)
+	List(filter PostFilter) ([]models.Post, error)
+	// Recent returns the newest published posts, of one author when
+	// accountID is set, without tags or attachments.
+	Recent(accountID uint, limit int) ([]models.Post, error)
+	// Each calls fn for every post of an account in ID order, loading
+	// them in batches.
+	Each(accountID uint, fn func(models.Post) error) error
+	// Create stores a new post with a fresh slug, the named tags and the
+	// author's attachments.
+	Create(post *models.Post, tags []string, attachmentIDs []uint) error
+	// Update writes updated over current, guarded by current's version.
+	// Nil tags or attachmentIDs keep the current ones and load them into updated.
+	Update(current models.Post, updated *models.Post, tags []string, attachmentIDs []uint) error
+	// UpdateBody replaces the body of a post still at version and
+	// returns the new version.
+	UpdateBody(id, version uint, body, bodyHTML string) (uint, error)
+	// LockComments stops or allows new comments and comment edits.
+	LockComments(id uint, locked bool) error
+	// Delete moves a post to the trash, guarded by its version.
+	Delete(post models.Post) error
+
+	// Trashed lists an account's deleted posts, most recently deleted first.
+	Trashed(accountID uint, offset, limit int) ([]models.Post, error)
+	GetTrashed(accountID, id uint) (models.Post, error)
+	// Restore brings a post back from the trash and returns it as stored.
+	Restore(post models.Post) (models.Post, error)
+
+	// IsCollaborator reports whether an account may co-edit a post.
+	IsCollaborator(postID, accountID uint) (bool, error)
+	Collaborators(postID uint) ([]Collaborator, error)
+	AddCollaborator(postID, accountID uint) error
+	RemoveCollaborator(postID, accountID uint) error
+}
+
+// CommentStore reads and writes comments.
+type CommentStore interface {
+	Get(id uint) (models.Comment, error)
+	Create(comment *models.Comment) error
+	// Threads returns one page of a post's top-level comments, oldest
+	// first, each with all its replies loaded into Replies, and the
+	// number of top-level comments.
+	Threads(postID uint, offset, limit int) ([]models.Comment, int64, error)
+	UpdateBody(id uint, body string) error
+	// DeleteThread deletes a comment together with all its replies.
+	DeleteThread(id uint) error
+}
+
+// ReactionStore records reactions and keeps their per-type counts.
+// Adding a reaction twice or removing a missing one changes nothing.
+type ReactionStore interface {
+	Add(postID, accountID uint, reaction string) error
+	Remove(postID, accountID uint, reaction string) error
+	// Counts returns the non-zero counts of the given posts.
+	Counts(postIDs []uint) ([]models.ReactionCount, error)
+	// Mine returns an account's reactions to the given posts.
+	Mine(postIDs []uint, accountID uint) ([]models.Reaction, error)
+}
+
+// TagCount is a tag together with the number of posts using it.
+type TagCount struct {
+	Slug  string
+	Count int64
+}
+
+// TagStore reads and reorganizes an account's tags.
+type TagStore interface {
+	Get(accountID uint, slug string) (models.Tag, error)
+	// List returns an account's tags with their post counts, by slug.
+	List(accountID uint) ([]TagCount, error)
+	// Rename changes a tag's slug, returning ErrConflict if the account
+	// already has one by that name. Like Merge, it bumps the version of
+	// every post carrying the tag.
+	Rename(tag models.Tag, slug string) error
+	// Merge moves every post of source to target and deletes source.
+	Merge(source, target models.Tag) error
+}
+
+// AttachmentStore reads and writes uploaded attachments.
+type AttachmentStore interface {
+	Get(id uint) (models.Attachment, error)
+	// GetByHash returns an account's attachment with the given content,
+	// with its variants.
+	GetByHash(accountID uint, hash string) (models.Attachment, error)
+	// List returns an account's attachments with their variants, newest first.
+	List(accountID uint) ([]models.Attachment, error)
+	// Usage is the number of bytes an account's attachments and their
+	// variants take up.
+	Usage(accountID uint) (int64, error)
+	// Create saves an attachment, or returns ErrQuotaExceeded if it would
+	// take the account's usage past quota and ErrConflict if the account
+	// already has the content. Concurrent uploads by the same account are
+	// checked one after the other.
+	Create(attachment *models.Attachment, quota int64) error
+	Delete(id uint) error
+	Variant(attachmentID uint, name string) (models.AttachmentVariant, error)
+	// Published reports whether a published post uses the attachment.
+	Published(id uint) (bool, error)
+	// InUse reports whether any post uses the attachment, trashed ones included.
+	InUse(id uint) (bool, error)
+}
+
+// FollowEntry is an account in a follower or following list.
+type FollowEntry struct {
+	ID         uint
+	Handle     string
+	FollowedAt time.Time
+}
+
+// FollowStore reads and writes the follow graph.
+type FollowStore interface {
+	// Follow reports whether the follow is new.
+	Follow(followerID, followeeID uint) (bool, error)
+	// Unfollow reports whether there was a follow to remove.
+	Unfollow(followerID, followeeID uint) (bool, error)
+	// Followers and Following page through an account's follows, newest
+	// first, and return their total.
+	Followers(accountID uint, offset, limit int) ([]FollowEntry, int64, error)
+	Following(accountID uint, offset, limit int) ([]FollowEntry, int64, error)
+	// FollowerIDs returns every follower of an account.
+	FollowerIDs(accountID uint) ([]uint, error)
+}
+
+// ImportStore tracks post import jobs.
+type ImportStore interface {
+	Create(job *models.ImportJob) error
+	// Get returns one of an account's import jobs.
+	Get(accountID, id uint) (models.ImportJob, error)
+	// Load returns an import job of any account.
+	Load(id uint) (models.ImportJob, error)
+	Save(job *models.ImportJob) error
+	SetStatus(id uint, status string) error
+	// MoveStatus sets status on every job in one of the from states and
+	// returns how many it changed.
+	MoveStatus(from []string, status string) (int64, error)
+}
+
+// WalletStore reads wallets.
+type WalletStore interface {
+	GetByAccount(accountID uint) (models.Wallet, error)
+}
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"strings"
+	"unicode"
+
+	"genesis/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TagSlug lowercases s and collapses every run of characters other than
+// letters and digits into a single dash.
+func TagSlug(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if b.Len() > 0 && !dash {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// normalizeTags slugifies names and drops empty and duplicate entries.
+func normalizeTags(names []string) []string {
+	seen := map[string]bool{}
+	var slugs []string
+	for _, name := range names {
+		slug := TagSlug(name)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		slugs = append(slugs, slug)
+	}
+	return slugs
+}
+
+// resolveTags returns the account's tags for names, creating missing ones.
+func resolveTags(tx *gorm.DB, accountID uint, names []string) ([]models.Tag, error) {
+	slugs := normalizeTags(names)
+	tags := []models.Tag{}
+	if len(slugs) == 0 {
+		return tags, nil
+	}
+	missing := make([]models.Tag, len(slugs))
+	for i, slug := range slugs {
+		missing[i] = models.Tag{AccountID: accountID, Slug: slug}
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&missing).Error; err != nil {
+		return nil, err
+	}
+	err := tx.Where("account_id = ? AND slug IN ?", accountID, slugs).Order("slug").Find(&tags).Error
+	return tags, err
+}
+
+// filterByTags restricts a post query to posts carrying any (or all) of names.
+func filterByTags(db, query *gorm.DB, accountID uint, names []string, all bool) *gorm.DB {
+	slugs := normalizeTags(names)
+	tagged := db.Table("post_tags").Select("post_tags.post_id").
+		Joins("JOIN tags ON tags.id = post_tags.tag_id").
+		Where("tags.account_id = ? AND tags.slug IN ?", accountID, slugs)
+	if all {
+		tagged = tagged.Group("post_tags.post_id").Having("COUNT(DISTINCT tags.id) = ?", len(slugs))
+	}
+	return query.Where("id IN (?)", tagged)
+}
+
+// resolveAttachments loads the given attachments, all of which must belong to accountID.
+func resolveAttachments(tx *gorm.DB, accountID uint, ids []uint) ([]models.Attachment, error) {
+	attachments := []models.Attachment{}
+	if len(ids) == 0 {
+		return attachments, nil
+	}
+	unique := map[uint]bool{}
+	for _, id := range ids {
+		unique[id] = true
+	}
+	if err := tx.Where("account_id = ? AND id IN ?", accountID, ids).Preload("Variants").Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	if len(attachments) != len(unique) {
+		return nil, ErrInvalidAttachment
+	}
+	return attachments, nil
+}
+
+type gormTags struct {
+	db *gorm.DB
+}
+
+func (s gormTags) Get(accountID uint, slug string) (models.Tag, error) {
+	var tag models.Tag
+	err := s.db.Where("account_id = ? AND slug = ?", accountID, slug).First(&tag).Error
+	return tag, notFound(err)
+}
+
+func (s gormTags) List(accountID uint) ([]TagCount, error) {
+	tags := []TagCount{}
+	err := s.db.Table("tags").
+		Select("tags.slug, COUNT(posts.id) AS count").
+		Joins("LEFT JOIN post_tags ON post_tags.tag_id = tags.id").
+		Joins("LEFT JOIN posts ON posts.id = post_tags.post_id AND posts.deleted_at IS NULL").
+		Where("tags.account_id = ?", accountID).
+		Group("tags.id, tags.slug").Order("tags.slug").
+		Scan(&tags).Error
+	return tags, err
+}
+
+// bumpTagged bumps the version of every post, trashed or not, carrying tag.
+func bumpTagged(tx *gorm.DB, tag models.Tag) error {
+	tagged := tx.Table("post_tags").Select("post_id").Where("tag_id = ?", tag.ID)
+	return tx.Unscoped().Model(&models.Post{}).Where("id IN (?)", tagged).
+		UpdateColumn("version", gorm.Expr("version + 1")).Error
+}
+
+func (s gormTags) Rename(tag models.Tag, slug string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&tag).Update("slug", slug).Error; err != nil {
+			return conflict(err)
+		}
+		return bumpTagged(tx, tag)
+	})
+}
+
+func (s gormTags) Merge(source, target models.Tag) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := bumpTagged(tx, source); err != nil {
+			return err
+		}
+		if err := tx.Exec(`INSERT INTO post_tags (post_id, tag_id)
+			SELECT post_id, ? FROM post_tags
+			WHERE tag_id = ? AND post_id NOT IN (SELECT post_id FROM post_tags WHERE tag_id = ?)`,
+			target.ID, source.ID, target.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM post_tags WHERE tag_id = ?", source.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&source).Error
+	})
+}
@@ -0,0 +1,17 @@
+package store
+
+import (
+	"genesis/models"
+
+	"gorm.io/gorm"
+)
+
+type gormWallets struct {
+	db *gorm.DB
+}
+
+func (s gormWallets) GetByAccount(accountID uint) (models.Wallet, error) {
+	var wallet models.Wallet
+	err := s.db.Where("account_id = ?", accountID).First(&wallet).Error
+	return wallet, notFound(err)
+}
@@ -0,0 +1,35 @@
+package timeline
+
+import (
+	"genesis/models"
+
+	"gorm.io/gorm"
+)
+
+// ReadStrategy is fan-out-on-read: each request joins the follow graph
+// with the posts table. Writes cost nothing.
+type ReadStrategy struct {
+	db *gorm.DB
+}
+
+func NewReadStrategy(db *gorm.DB) *ReadStrategy {
+	return &ReadStrategy{db: db}
+}
+
+func (s *ReadStrategy) Home(accountID uint, before *Cursor, limit int) ([]Cursor, error) {
+	followees := s.db.Model(&models.Follow{}).Select("followee_id").Where("follower_id = ?", accountID)
+	query := s.db.Model(&models.Post{}).Select("id AS post_id, created_at").
+		Where("account_id IN (?) AND draft = ?", followees, false).
+		Where("account_id IN (?)", s.db.Model(&models.Account{}).Select("id"))
+	if before != nil {
+		query = query.Where("created_at < ? OR (created_at = ? AND id < ?)", before.CreatedAt, before.CreatedAt, before.PostID)
+	}
+	var page []Cursor
+	err := query.Order("created_at DESC, id DESC").Limit(limit).Scan(&page).Error
+	return page, err
+}
+
+func (s *ReadStrategy) PostChanged(models.Post) error { return nil }
+func (s *ReadStrategy) PostRemoved(uint) error        { return nil }
+func (s *ReadStrategy) Followed(uint, uint) error     { return nil }
+func (s *ReadStrategy) Unfollowed(uint, uint) error   { return nil }
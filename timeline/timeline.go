@@ -0,0 +1,66 @@
+// Package timeline builds home timelines from the follow graph.
+package timeline
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"genesis/models"
+)
+
+// ErrBadCursor is returned when a cursor cannot be decoded.
+var ErrBadCursor = errors.New("invalid cursor")
+
+// Cursor marks the last entry of a page. Timelines are ordered newest
+// first by post creation time, with the post ID breaking ties.
+type Cursor struct {
+	CreatedAt time.Time
+	PostID    uint
+}
+
+// Encode renders the cursor as an opaque URL-safe token.
+func (c Cursor) Encode() string {
+	raw := fmt.Sprintf("%d:%d", c.CreatedAt.UnixNano(), c.PostID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a token produced by Cursor.Encode.
+func DecodeCursor(token string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrBadCursor
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, ErrBadCursor
+	}
+	n, err1 := strconv.ParseInt(nanos, 10, 64)
+	p, err2 := strconv.ParseUint(id, 10, 64)
+	if err1 != nil || err2 != nil {
+		return nil, ErrBadCursor
+	}
+	return &Cursor{CreatedAt: time.Unix(0, n).UTC(), PostID: uint(p)}, nil
+}
+
+// Strategy produces home timelines. Fan-out-on-read computes them from
+// the follow graph at request time; fan-out-on-write materializes them
+// as posts are published. Handlers report every change through the hooks
+// so strategies can be swapped without touching them.
+type Strategy interface {
+	// Home returns up to limit post IDs for the account's home timeline,
+	// strictly older than before when it is set.
+	Home(accountID uint, before *Cursor, limit int) ([]Cursor, error)
+
+	// PostChanged is called after a post is created or updated. Drafts
+	// must not appear in timelines.
+	PostChanged(post models.Post) error
+	// PostRemoved is called after a post is deleted.
+	PostRemoved(postID uint) error
+	// Followed and Unfollowed are called after the follow graph changes.
+	Followed(followerID, followeeID uint) error
+	Unfollowed(followerID, followeeID uint) error
+}
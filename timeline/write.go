@@ -0,0 +1,87 @@
+package timeline
+
+import (
+	"genesis/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// fanoutBatch is how many followers get an entry per insert.
+	fanoutBatch = 500
+	// backfillLimit is how many recent posts a new follow copies in.
+	backfillLimit = 200
+)
+
+// WriteStrategy is fan-out-on-write: publishing a post inserts an entry
+// into every follower's timeline, so reads are a single indexed scan.
+type WriteStrategy struct {
+	db *gorm.DB
+}
+
+func NewWriteStrategy(db *gorm.DB) *WriteStrategy {
+	return &WriteStrategy{db: db}
+}
+
+func (s *WriteStrategy) Home(accountID uint, before *Cursor, limit int) ([]Cursor, error) {
+	query := s.db.Model(&models.TimelineEntry{}).Select("post_id, created_at").
+		Where("account_id = ? AND author_id IN (?)", accountID, s.db.Model(&models.Account{}).Select("id"))
+	if before != nil {
+		query = query.Where("created_at < ? OR (created_at = ? AND post_id < ?)", before.CreatedAt, before.CreatedAt, before.PostID)
+	}
+	var page []Cursor
+	err := query.Order("created_at DESC, post_id DESC").Limit(limit).Scan(&page).Error
+	return page, err
+}
+
+func (s *WriteStrategy) PostChanged(post models.Post) error {
+	if post.Draft {
+		return s.PostRemoved(post.ID)
+	}
+	var followers []models.Follow
+	return s.db.Where("followee_id = ?", post.AccountID).
+		FindInBatches(&followers, fanoutBatch, func(tx *gorm.DB, batch int) error {
+			entries := make([]models.TimelineEntry, len(followers))
+			for i, f := range followers {
+				entries[i] = models.TimelineEntry{
+					AccountID: f.FollowerID,
+					PostID:    post.ID,
+					AuthorID:  post.AccountID,
+					CreatedAt: post.CreatedAt,
+				}
+			}
+			return s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&entries).Error
+		}).Error
+}
+
+func (s *WriteStrategy) PostRemoved(postID uint) error {
+	return s.db.Where("post_id = ?", postID).Delete(&models.TimelineEntry{}).Error
+}
+
+func (s *WriteStrategy) Followed(followerID, followeeID uint) error {
+	var posts []models.Post
+	if err := s.db.Select("id, account_id, created_at").
+		Where("account_id = ? AND draft = ?", followeeID, false).
+		Order("created_at DESC").Limit(backfillLimit).Find(&posts).Error; err != nil {
+		return err
+	}
+	if len(posts) == 0 {
+		return nil
+	}
+	entries := make([]models.TimelineEntry, len(posts))
+	for i, post := range posts {
+		entries[i] = models.TimelineEntry{
+			AccountID: followerID,
+			PostID:    post.ID,
+			AuthorID:  followeeID,
+			CreatedAt: post.CreatedAt,
+		}
+	}
+	return s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&entries).Error
+}
+
+func (s *WriteStrategy) Unfollowed(followerID, followeeID uint) error {
+	return s.db.Where("account_id = ? AND author_id = ?", followerID, followeeID).
+		Delete(&models.TimelineEntry{}).Error
+}
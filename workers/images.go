@@ -0,0 +1,194 @@
+// Package workers runs background jobs next to the HTTP server.
+package workers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"genesis/imaging"
+	"genesis/models"
+	"genesis/storage"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Variant generation states stored on models.Attachment.
+const (
+	VariantsPending = "pending"
+	VariantsReady   = "ready"
+	VariantsFailed  = "failed"
+)
+
+const (
+	imageSweepInterval = time.Minute
+	// statusAttempts is how often a variant status is written before the
+	// attachment is left pending for the next sweep.
+	statusAttempts = 3
+	statusRetry    = time.Second
+)
+
+// ImageWorker generates the variants of uploaded images.
+type ImageWorker struct {
+	db    *gorm.DB
+	blobs storage.BlobStore
+	queue chan uint
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// NewImageWorker returns an image worker reading attachments from db and
+// their content from blobs. Start runs it.
+func NewImageWorker(db *gorm.DB, blobs storage.BlobStore) *ImageWorker {
+	return &ImageWorker{db: db, blobs: blobs, queue: make(chan uint, 256), stop: make(chan struct{})}
+}
+
+// Start starts generating image variants in the background. Besides
+// attachments handed to Enqueue it periodically sweeps for pending ones,
+// which covers restarts, a full queue and failed sweeps or status writes.
+func (w *ImageWorker) Start() {
+	w.wg.Add(2)
+	go func() {
+		defer w.wg.Done()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case id := <-w.queue:
+				w.process(id)
+			}
+		}
+	}()
+	go func() {
+		defer w.wg.Done()
+		for {
+			var pending []uint
+			err := w.db.Model(&models.Attachment{}).
+				Where("variant_status = ?", VariantsPending).Pluck("id", &pending).Error
+			if err != nil {
+				slog.Error("Failed to sweep pending images", "error", err)
+			}
+			for _, id := range pending {
+				w.Enqueue(id)
+			}
+			select {
+			case <-w.stop:
+				return
+			case <-time.After(imageSweepInterval):
+			}
+		}
+	}()
+}
+
+// Stop waits for the image being processed, if any, and stops the
+// worker. Attachments still queued stay pending for the next start.
+func (w *ImageWorker) Stop() {
+	close(w.stop)
+	w.wg.Wait()
+}
+
+// Enqueue schedules variant generation for an attachment without
+// blocking; if the queue is full the next sweep picks it up.
+func (w *ImageWorker) Enqueue(id uint) {
+	select {
+	case w.queue <- id:
+	default:
+	}
+}
+
+func (w *ImageWorker) process(id uint) {
+	var attachment models.Attachment
+	if err := w.db.First(&attachment, id).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.Error("Failed to fetch attachment", "attachment_id", id, "error", err)
+		}
+		return
+	}
+	if attachment.VariantStatus != VariantsPending {
+		return
+	}
+	ctx := context.Background()
+
+	status := VariantsReady
+	if err := w.generateVariants(ctx, attachment); err != nil {
+		slog.Error("Failed to generate variants", "attachment_id", id, "error", err)
+		status = VariantsFailed
+	}
+	w.setStatus(attachment, status)
+}
+
+// setStatus records the variant status of attachment, retrying briefly.
+// An attachment whose status could not be written stays pending, and the
+// next sweep generates its variants again.
+func (w *ImageWorker) setStatus(attachment models.Attachment, status string) {
+	for attempt := 1; ; attempt++ {
+		err := w.db.Model(&attachment).Update("variant_status", status).Error
+		if err == nil {
+			return
+		}
+		slog.Error("Failed to save variant status", "attachment_id", attachment.ID, "status", status, "attempt", attempt, "error", err)
+		if attempt == statusAttempts {
+			return
+		}
+		select {
+		case <-w.stop:
+			return
+		case <-time.After(time.Duration(attempt) * statusRetry):
+		}
+	}
+}
+
+func (w *ImageWorker) generateVariants(ctx context.Context, attachment models.Attachment) error {
+	blob, err := w.blobs.Get(ctx, attachment.Hash)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+	variants, err := imaging.Generate(blob)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range variants {
+		if err := GuardBlobs(func() error { return w.saveVariant(ctx, attachment.ID, v) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveVariant stores the blob of v unless it is already stored, and
+// records v as a variant of an attachment. It must run under GuardBlobs,
+// since the blob may be shared with a variant being collected.
+func (w *ImageWorker) saveVariant(ctx context.Context, attachmentID uint, v imaging.Variant) error {
+	sum := sha256.Sum256(v.Data)
+	hash := hex.EncodeToString(sum[:])
+	stored, err := w.blobs.Exists(ctx, hash)
+	if err != nil {
+		return err
+	}
+	if !stored {
+		if err := w.blobs.Put(ctx, hash, bytes.NewReader(v.Data), int64(len(v.Data)), v.MIMEType); err != nil {
+			return err
+		}
+	}
+	row := models.AttachmentVariant{
+		AttachmentID: attachmentID,
+		Name:         v.Name,
+		Hash:         hash,
+		Size:         int64(len(v.Data)),
+		MIMEType:     v.MIMEType,
+		Width:        v.Width,
+		Height:       v.Height,
+	}
+	return w.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "attachment_id"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"hash", "size", "mime_type", "width", "height"}),
+	}).Create(&row).Error
+}
@@ -0,0 +1,259 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"genesis/models"
+	"genesis/search"
+	"genesis/storage"
+	"genesis/timeline"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const purgeInterval = time.Hour
+
+// Purger permanently deletes trashed posts and accounts along with
+// everything that only they used.
+type Purger struct {
+	db       *gorm.DB
+	blobs    storage.BlobStore
+	search   search.Searcher
+	timeline timeline.Strategy
+	// retention is how long soft-deleted posts and accounts are kept
+	// before they are removed for good.
+	retention time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewPurger returns a purger that also keeps the blob store, search index
+// and timelines in line with what it deletes, purging trash older than
+// retention.
+func NewPurger(db *gorm.DB, blobs storage.BlobStore, index search.Searcher, timeline timeline.Strategy, retention time.Duration) *Purger {
+	return &Purger{
+		db:        db,
+		blobs:     blobs,
+		search:    index,
+		timeline:  timeline,
+		retention: retention,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start periodically hard-deletes posts and accounts that have been in
+// the trash for longer than the retention.
+func (p *Purger) Start() {
+	go func() {
+		defer close(p.done)
+		for {
+			p.purgeExpired(time.Now().Add(-p.retention))
+			select {
+			case <-p.stop:
+				return
+			case <-time.After(purgeInterval):
+			}
+		}
+	}()
+}
+
+// Stop waits for a running purge to finish and stops the purger.
+func (p *Purger) Stop() {
+	close(p.stop)
+	<-p.done
+}
+
+func (p *Purger) purgeExpired(cutoff time.Time) {
+	var posts, accounts []uint
+	if err := p.db.Unscoped().Model(&models.Post{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Pluck("id", &posts).Error; err != nil {
+		slog.Error("Failed to find expired posts", "error", err)
+	}
+	for _, id := range posts {
+		if err := p.PurgePost(id); err != nil {
+			slog.Error("Failed to purge post", "post_id", id, "error", err)
+		}
+	}
+	if err := p.db.Unscoped().Model(&models.Account{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Pluck("id", &accounts).Error; err != nil {
+		slog.Error("Failed to find expired accounts", "error", err)
+	}
+	for _, id := range accounts {
+		if err := p.PurgeAccount(id); err != nil {
+			slog.Error("Failed to purge account", "account_id", id, "error", err)
+		}
+	}
+}
+
+// PurgePost permanently deletes a post and everything cascading from it,
+// then collects the attachments only it used.
+func (p *Purger) PurgePost(id uint) error {
+	var attachmentIDs []uint
+	if err := p.db.Table("post_attachments").Where("post_id = ?", id).Pluck("attachment_id", &attachmentIDs).Error; err != nil {
+		return err
+	}
+	if err := p.db.Unscoped().Delete(&models.Post{}, id).Error; err != nil {
+		return err
+	}
+	p.forgetPosts([]uint{id})
+	return p.CollectAttachments(attachmentIDs)
+}
+
+// PurgeAccount permanently deletes an account with its posts, uploads and
+// follows, and removes blobs nothing else shares. Its wallet is kept as
+// a tombstone so that other accounts' transfers survive.
+func (p *Purger) PurgeAccount(id uint) error {
+	var postIDs []uint
+	if err := p.db.Unscoped().Model(&models.Post{}).Where("account_id = ?", id).Pluck("id", &postIDs).Error; err != nil {
+		return err
+	}
+	var hashes, variantHashes []string
+	if err := p.db.Model(&models.Attachment{}).Where("account_id = ?", id).Pluck("hash", &hashes).Error; err != nil {
+		return err
+	}
+	if err := p.db.Model(&models.AttachmentVariant{}).
+		Where("attachment_id IN (?)", p.db.Model(&models.Attachment{}).Select("id").Where("account_id = ?", id)).
+		Pluck("hash", &variantHashes).Error; err != nil {
+		return err
+	}
+
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		// Reactions the account gave on other authors' posts leave
+		// counts behind that the cascade would not correct.
+		if err := releaseReactions(tx, id); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.Wallet{}).Where("account_id = ?", id).Updates(map[string]interface{}{
+			"account_id": nil,
+			"deleted_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&models.Account{}, id).Error
+	})
+	if err != nil {
+		return err
+	}
+	p.forgetPosts(postIDs)
+	return p.deleteUnusedBlobs(append(hashes, variantHashes...))
+}
+
+// releaseReactions deletes an account's reactions and takes them off the
+// aggregated counts.
+func releaseReactions(tx *gorm.DB, accountID uint) error {
+	var reactions []models.Reaction
+	if err := tx.Where("account_id = ?", accountID).Find(&reactions).Error; err != nil {
+		return err
+	}
+	for _, r := range reactions {
+		if err := tx.Model(&models.ReactionCount{}).
+			Where("post_id = ? AND type = ? AND count > 0", r.PostID, r.Type).
+			Update("count", gorm.Expr("count - 1")).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Where("account_id = ?", accountID).Delete(&models.Reaction{}).Error
+}
+
+// forgetPosts drops purged posts from the search index and timelines.
+func (p *Purger) forgetPosts(ids []uint) {
+	for _, id := range ids {
+		if err := p.search.Remove(id); err != nil {
+			slog.Error("Failed to remove post from index", "post_id", id, "error", err)
+		}
+		if err := p.timeline.PostRemoved(id); err != nil {
+			slog.Error("Failed to remove post from timelines", "post_id", id, "error", err)
+		}
+	}
+}
+
+// CollectAttachments removes attachments no post references any more,
+// including posts in the trash, and deletes their blobs and variant blobs
+// once nothing else shares the hash.
+func (p *Purger) CollectAttachments(ids []uint) error {
+	for _, id := range ids {
+		var attachment models.Attachment
+		err := p.db.Preload("Variants").First(&attachment, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		var refs int64
+		if err := p.db.Table("post_attachments").Where("attachment_id = ?", id).Count(&refs).Error; err != nil {
+			return err
+		}
+		if refs > 0 {
+			continue
+		}
+		if err := p.db.Select(clause.Associations).Delete(&attachment).Error; err != nil {
+			return err
+		}
+		hashes := []string{attachment.Hash}
+		for _, v := range attachment.Variants {
+			hashes = append(hashes, v.Hash)
+		}
+		if err := p.deleteUnusedBlobs(hashes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blobWriters is held for reading by writers that make sure a blob is
+// stored for a record referencing it, and for writing while a blob is
+// checked for references and deleted. It only covers this process.
+var blobWriters sync.RWMutex
+
+// GuardBlobs runs fn, which stores blobs for records that reference them
+// or are about to, without garbage collection deleting any of them in
+// between. Records created before fn runs are seen by the collection.
+func GuardBlobs(fn func() error) error {
+	blobWriters.RLock()
+	defer blobWriters.RUnlock()
+	return fn()
+}
+
+// deleteUnusedBlobs deletes the blobs of hashes no record uses. Failing
+// blob deletions only leave garbage behind, so they are logged.
+func (p *Purger) deleteUnusedBlobs(hashes []string) error {
+	for _, hash := range hashes {
+		if err := p.deleteUnusedBlob(hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Purger) deleteUnusedBlob(hash string) error {
+	blobWriters.Lock()
+	defer blobWriters.Unlock()
+	referenced, err := p.blobReferenced(hash)
+	if err != nil || referenced {
+		return err
+	}
+	if err := p.blobs.Delete(context.Background(), hash); err != nil {
+		slog.Error("Failed to delete blob", "hash", hash, "error", err)
+	}
+	return nil
+}
+
+// blobReferenced reports whether any attachment or variant still uses a blob.
+func (p *Purger) blobReferenced(hash string) (bool, error) {
+	var uploads, variants int64
+	if err := p.db.Model(&models.Attachment{}).Where("hash = ?", hash).Count(&uploads).Error; err != nil {
+		return false, err
+	}
+	if err := p.db.Model(&models.AttachmentVariant{}).Where("hash = ?", hash).Count(&variants).Error; err != nil {
+		return false, err
+	}
+	return uploads+variants > 0, nil
+}